@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	documentStaff "BackendKantorDinsos/domain/document_staff"
+)
+
+// runCommand menjalankan perintah maintenance, misalnya:
+//
+//	go run . reconcile [-repair]
+//	go run . migrate-private-delivery
+//	go run . thumbnails
+//	go run . verify [-backfill]
+//	go run . rotate-keys
+//	go run . extract-text [-all]
+//	go run . reindex-search
+//	go run . map-document-types [-apply]
+//
+// Mengembalikan false jika argumen bukan perintah yang dikenal.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "reconcile":
+		flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+		repair := flags.Bool("repair", false, "hapus file orphan dan pindahkan dokumen yang filenya hilang ke trash")
+		flags.Parse(args[1:])
+
+		report, err := documentStaff.Reconcile(*repair)
+		if err != nil {
+			log.Fatal("❌ Rekonsiliasi gagal:", err)
+		}
+		printJSON(report)
+	case "migrate-private-delivery":
+		report, err := documentStaff.MigratePrivateDelivery()
+		if err != nil {
+			log.Fatal("❌ Migrasi delivery privat gagal:", err)
+		}
+		printJSON(report)
+	case "thumbnails":
+		created, err := documentStaff.BackfillThumbnails()
+		if err != nil {
+			log.Fatal("❌ Pembuatan thumbnail gagal:", err)
+		}
+		log.Printf("✅ %d thumbnail dibuat", created)
+	case "verify":
+		flags := flag.NewFlagSet("verify", flag.ExitOnError)
+		backfill := flags.Bool("backfill", false, "isi checksum dan ukuran file yang belum tercatat")
+		flags.Parse(args[1:])
+
+		report, err := documentStaff.VerifyIntegrity(*backfill)
+		if err != nil {
+			log.Fatal("❌ Verifikasi integritas gagal:", err)
+		}
+		printJSON(report)
+
+		if report.Failed > 0 {
+			os.Exit(1)
+		}
+	case "rotate-keys":
+		rotated, err := documentStaff.RotateStorageKeys()
+		if err != nil {
+			log.Fatal("❌ Rotasi data key gagal:", err)
+		}
+		log.Printf("✅ %d data key dibungkus ulang dengan master key aktif", rotated)
+	case "extract-text":
+		flags := flag.NewFlagSet("extract-text", flag.ExitOnError)
+		all := flags.Bool("all", false, "ekstrak ulang semua dokumen, termasuk yang sudah berhasil")
+		flags.Parse(args[1:])
+
+		processed, err := documentStaff.ExtractTexts(*all)
+		if err != nil {
+			log.Fatal("❌ Ekstraksi teks gagal:", err)
+		}
+		log.Printf("✅ Teks %d dokumen diekstrak", processed)
+	case "reindex-search":
+		processed, err := documentStaff.ReindexSearch()
+		if err != nil {
+			log.Fatal("❌ Pembuatan ulang index pencarian gagal:", err)
+		}
+		log.Printf("✅ %d dokumen diindeks ulang", processed)
+	case "map-document-types":
+		flags := flag.NewFlagSet("map-document-types", flag.ExitOnError)
+		apply := flags.Bool("apply", false, "simpan jenis dokumen hasil pemetaan subject")
+		flags.Parse(args[1:])
+
+		report, err := documentStaff.MapDocumentTypes(*apply)
+		if err != nil {
+			log.Fatal("❌ Pemetaan jenis dokumen gagal:", err)
+		}
+		printJSON(report)
+	default:
+		return false
+	}
+
+	return true
+}
+
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,231 @@
+package document_staff
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	accessDownload        = "download"
+	accessVersionDownload = "version_download"
+	accessURL             = "signed_url"
+)
+
+// DocumentAccessLog mencatat siapa yang membuka file dokumen, kapan, dan dari
+// mana.
+type DocumentAccessLog struct {
+	ID         string    `gorm:"type:char(36);primaryKey" json:"id"`
+	DocumentID string    `gorm:"type:char(36);index" json:"document_id"`
+	VersionID  string    `gorm:"type:char(36)" json:"version_id,omitempty"`
+	EmployeeID string    `gorm:"type:char(36);index" json:"employee_id"`
+	Role       string    `gorm:"type:varchar(20)" json:"role"`
+	Action     string    `gorm:"type:varchar(30)" json:"action"`
+	IPAddress  string    `gorm:"type:varchar(45)" json:"ip_address"`
+	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (l *DocumentAccessLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == "" {
+		l.ID = uuid.NewString()
+	}
+	return
+}
+
+// recordAccess menyimpan log akses. Request lanjutan dari PDF viewer (Range
+// yang tidak dimulai dari byte 0) tidak dicatat ulang agar satu kali buka
+// file tidak menghasilkan puluhan baris.
+func recordAccess(c *gin.Context, documentID, versionID, action string) {
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-") {
+		return
+	}
+
+	role, employeeID := callerIdentity(c)
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	entry := DocumentAccessLog{
+		DocumentID: documentID,
+		VersionID:  versionID,
+		EmployeeID: employeeID,
+		Role:       role,
+		Action:     action,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  userAgent,
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("⚠️ Gagal mencatat akses dokumen %s: %v", documentID, err)
+	}
+}
+
+// storedFile adalah informasi file yang dibutuhkan untuk mengirim file ke
+// client beserta header cache-nya.
+type storedFile struct {
+	PublicID     string
+	ResourceType string
+	FileName     string
+	MimeType     string
+	Checksum     string
+	ModTime      time.Time
+	Inline       bool
+
+	// OnOpen dipanggil sekali setelah isi file pertama kali berhasil dibaca
+	// dari storage, misalnya untuk mencatat akses. Request yang gagal membuka
+	// file, 304, dan HEAD tidak memanggilnya.
+	OnOpen func()
+}
+
+// openNotifier memanggil onOpen pada Read pertama yang menghasilkan data.
+type openNotifier struct {
+	io.ReadSeeker
+	onOpen func()
+}
+
+func (r *openNotifier) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	if n > 0 && r.onOpen != nil {
+		r.onOpen()
+		r.onOpen = nil
+	}
+	return n, err
+}
+
+// serveStoredFile mengalirkan file dari storage ke client sebagai attachment.
+// Backend yang mendukung RangeOpener dilayani lewat http.ServeContent sehingga
+// Range, If-Range, If-None-Match dan If-Modified-Since ditangani otomatis.
+func serveStoredFile(c *gin.Context, file storedFile) {
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(filepath.Ext(file.FileName)))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "attachment"
+	if file.Inline {
+		disposition = "inline"
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.FileName}))
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("X-Content-Type-Options", "nosniff")
+	if file.Checksum != "" {
+		header.Set("ETag", strconv.Quote(file.Checksum))
+	}
+
+	if opener, ok := store.(storage.RangeOpener); ok {
+		content, err := storage.NewRangeReadSeeker(opener, file.PublicID, file.ResourceType)
+		if err != nil {
+			header.Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka file: " + err.Error()})
+			return
+		}
+		defer content.Close()
+
+		http.ServeContent(c.Writer, c.Request, file.FileName, file.ModTime, &openNotifier{content, file.OnOpen})
+		return
+	}
+
+	reader, err := store.Open(file.PublicID, file.ResourceType)
+	if err != nil {
+		header.Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka file: " + err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	if !file.ModTime.IsZero() {
+		header.Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	if file.Checksum != "" && c.GetHeader("If-None-Match") == strconv.Quote(file.Checksum) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if file.OnOpen != nil {
+		file.OnOpen()
+	}
+
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("⚠️ Gagal mengirim file %s: %v", file.PublicID, err)
+	}
+}
+
+// ======================================================
+// GET DOCUMENT ACCESS LOGS - ADMIN ONLY
+// ======================================================
+func GetDocumentAccessLogs(c *gin.Context) {
+	documentID := c.Param("id")
+
+	var document DocumentStaff
+	if err := database.DB.Unscoped().Select("id").First(&document, "id = ?", documentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan"})
+		return
+	}
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		pageInt = 1
+	}
+
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 {
+		limitInt = 20
+	}
+
+	if limitInt > 100 {
+		limitInt = 100
+	}
+
+	query := database.DB.Model(&DocumentAccessLog{}).Where("document_id = ?", documentID)
+
+	var total int64
+	query.Count(&total)
+
+	var logs []DocumentAccessLog
+	if err := query.
+		Order("created_at DESC").
+		Limit(limitInt).
+		Offset((pageInt - 1) * limitInt).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Gagal mengambil log akses: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil log akses dokumen",
+		"data": gin.H{
+			"logs": logs,
+			"pagination": gin.H{
+				"current_page": pageInt,
+				"per_page":     limitInt,
+				"total_items":  total,
+				"total_pages":  int(math.Ceil(float64(total) / float64(limitInt))),
+			},
+		},
+	})
+}
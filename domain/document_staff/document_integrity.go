@@ -0,0 +1,246 @@
+package document_staff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	IntegrityOK         = "ok"
+	IntegrityMismatch   = "mismatch"
+	IntegrityMissing    = "missing"
+	IntegrityUnverified = "unverified"
+	IntegrityBackfilled = "backfilled"
+)
+
+// IntegrityCheck menyimpan hasil setiap verifikasi file sebagai jejak audit
+// bahwa isi file yang tersimpan sama dengan saat diupload.
+type IntegrityCheck struct {
+	ID               string    `gorm:"type:char(36);primaryKey" json:"id"`
+	DocumentID       string    `gorm:"type:char(36);index" json:"document_id"`
+	VersionID        string    `gorm:"type:char(36)" json:"version_id,omitempty"`
+	PublicID         string    `gorm:"type:varchar(255)" json:"public_id"`
+	Status           string    `gorm:"type:varchar(20);index" json:"status"`
+	ExpectedChecksum string    `gorm:"type:char(64)" json:"expected_checksum"`
+	ActualChecksum   string    `gorm:"type:char(64)" json:"actual_checksum"`
+	ExpectedSize     int64     `json:"expected_size"`
+	ActualSize       int64     `json:"actual_size"`
+	Error            string    `gorm:"type:text" json:"error,omitempty"`
+	CheckedAt        time.Time `json:"checked_at"`
+}
+
+func (i *IntegrityCheck) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == "" {
+		i.ID = uuid.NewString()
+	}
+	return
+}
+
+// IntegrityReport merangkum hasil verifikasi banyak file.
+type IntegrityReport struct {
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Checked    int              `json:"checked"`
+	Failed     int              `json:"failed"`
+	Results    []IntegrityCheck `json:"results"`
+}
+
+func (r *IntegrityReport) add(check IntegrityCheck) {
+	r.Checked++
+	if check.Status == IntegrityMismatch || check.Status == IntegrityMissing {
+		r.Failed++
+	}
+	r.Results = append(r.Results, check)
+}
+
+// verifyFile mengunduh file dari storage, menghitung ulang SHA-256 dan
+// ukurannya, lalu membandingkan dengan nilai yang tercatat.
+func verifyFile(publicID, resourceType, checksum string, size int64) IntegrityCheck {
+	check := IntegrityCheck{
+		PublicID:         publicID,
+		ExpectedChecksum: checksum,
+		ExpectedSize:     size,
+		CheckedAt:        time.Now(),
+	}
+
+	file, err := store.Open(publicID, resourceType)
+	if err != nil {
+		check.Status = IntegrityMissing
+		check.Error = err.Error()
+		return check
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, file)
+	if err != nil {
+		check.Status = IntegrityMissing
+		check.Error = err.Error()
+		return check
+	}
+
+	check.ActualChecksum = hex.EncodeToString(hasher.Sum(nil))
+	check.ActualSize = n
+
+	switch {
+	case checksum == "":
+		check.Status = IntegrityUnverified
+	case checksum != check.ActualChecksum || (size > 0 && size != n):
+		check.Status = IntegrityMismatch
+	default:
+		check.Status = IntegrityOK
+	}
+
+	return check
+}
+
+// verifyDocument memverifikasi file dokumen beserta seluruh versinya. Jika
+// backfill bernilai true, checksum dan ukuran yang belum tercatat (file dari
+// sebelum fitur ini ada) diisi dari hasil perhitungan.
+func verifyDocument(document DocumentStaff, backfill bool, report *IntegrityReport) error {
+	if document.PublicID != "" {
+		check := verifyFile(document.PublicID, document.ResourceType, document.Checksum, document.Size)
+		check.DocumentID = document.ID
+
+		if backfill && needsBackfill(check) {
+			if err := database.DB.Unscoped().Model(&document).UpdateColumns(map[string]interface{}{
+				"checksum": check.ActualChecksum,
+				"size":     check.ActualSize,
+			}).Error; err != nil {
+				return err
+			}
+			check.Status = IntegrityBackfilled
+		}
+
+		if err := saveIntegrityCheck(&check); err != nil {
+			return err
+		}
+		report.add(check)
+	}
+
+	var versions []DocumentVersion
+	if err := database.DB.Where("document_id = ? AND public_id <> ''", document.ID).Find(&versions).Error; err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		check := verifyFile(version.PublicID, version.ResourceType, version.Checksum, version.Size)
+		check.DocumentID = document.ID
+		check.VersionID = version.ID
+
+		if backfill && needsBackfill(check) {
+			if err := database.DB.Model(&version).Updates(map[string]interface{}{
+				"checksum": check.ActualChecksum,
+				"size":     check.ActualSize,
+			}).Error; err != nil {
+				return err
+			}
+			check.Status = IntegrityBackfilled
+		}
+
+		if err := saveIntegrityCheck(&check); err != nil {
+			return err
+		}
+		report.add(check)
+	}
+
+	return nil
+}
+
+// needsBackfill bernilai true untuk file tanpa checksum, atau file dengan
+// checksum cocok tetapi ukurannya belum tercatat.
+func needsBackfill(check IntegrityCheck) bool {
+	if check.ActualChecksum == "" {
+		return false
+	}
+
+	return check.Status == IntegrityUnverified ||
+		(check.ExpectedSize == 0 && check.ExpectedChecksum == check.ActualChecksum)
+}
+
+func saveIntegrityCheck(check *IntegrityCheck) error {
+	return database.DB.Create(check).Error
+}
+
+// VerifyIntegrity memverifikasi semua dokumen, termasuk yang ada di trash.
+func VerifyIntegrity(backfill bool) (IntegrityReport, error) {
+	report := IntegrityReport{StartedAt: time.Now(), Results: []IntegrityCheck{}}
+
+	var documents []DocumentStaff
+	if err := database.DB.Unscoped().Order("created_at ASC").Find(&documents).Error; err != nil {
+		return report, err
+	}
+
+	for _, document := range documents {
+		if err := verifyDocument(document, backfill, &report); err != nil {
+			log.Printf("⚠️ Gagal memverifikasi dokumen %s: %v", document.ID, err)
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// ======================================================
+// VERIFY DOCUMENT INTEGRITY - ADMIN ONLY
+// ======================================================
+func VerifyDocumentStaffAdmin(c *gin.Context) {
+	var document DocumentStaff
+	if err := database.DB.Unscoped().First(&document, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan"})
+		return
+	}
+
+	report := IntegrityReport{StartedAt: time.Now(), Results: []IntegrityCheck{}}
+	if err := verifyDocument(document, c.Query("backfill") == "true", &report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memverifikasi dokumen: " + err.Error()})
+		return
+	}
+	report.FinishedAt = time.Now()
+
+	message := "Semua file dokumen utuh"
+	if report.Failed > 0 {
+		message = "Ditemukan file yang rusak atau berubah"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"report":  report,
+	})
+}
+
+// ======================================================
+// GET INTEGRITY HISTORY - ADMIN ONLY
+// ======================================================
+func GetDocumentIntegrityChecks(c *gin.Context) {
+	var document DocumentStaff
+	if err := database.DB.Unscoped().Select("id").First(&document, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan"})
+		return
+	}
+
+	var checks []IntegrityCheck
+	if err := database.DB.
+		Where("document_id = ?", document.ID).
+		Order("checked_at DESC").
+		Limit(100).
+		Find(&checks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat verifikasi: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil riwayat verifikasi dokumen",
+		"checks":  checks,
+	})
+}
@@ -0,0 +1,378 @@
+package document_staff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/pdfbuild"
+)
+
+const methodOCR = "ocr"
+
+// ocrMinChars adalah jumlah minimal huruf/angka hasil pdftotext agar PDF
+// dianggap punya lapisan teks. Di bawah itu PDF dianggap hasil scan.
+const ocrMinChars = 20
+
+// ocrDPI adalah resolusi render halaman PDF untuk OCR.
+const ocrDPI = 300
+
+// ocrLanguages diatur lewat env OCR_LANG dengan format Tesseract, misalnya
+// "ind" atau "ind+eng" (default "ind").
+func ocrLanguages() string {
+	if lang := strings.TrimSpace(os.Getenv("OCR_LANG")); lang != "" {
+		return lang
+	}
+
+	return "ind"
+}
+
+// ocrMaxPages membatasi jumlah halaman PDF yang di-OCR, diatur lewat env
+// OCR_MAX_PAGES (default 50).
+func ocrMaxPages() int {
+	if n, err := strconv.Atoi(os.Getenv("OCR_MAX_PAGES")); err == nil && n > 0 {
+		return n
+	}
+
+	return 50
+}
+
+// ocrWorkers adalah jumlah proses OCR yang berjalan bersamaan, diatur lewat
+// env OCR_WORKERS (default 1). OCR memakai CPU penuh, jadi nilainya sebaiknya
+// tidak melebihi jumlah core.
+func ocrWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv("OCR_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+var ocrQueue = make(chan string, 256)
+
+// needsOCR menentukan apakah dokumen perlu OCR setelah ekstraksi teks biasa:
+// semua gambar, dan PDF yang berhasil dibaca pdftotext tetapi hampir tanpa
+// teks.
+func needsOCR(mimeType, content string, err error) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return true
+	case mimeType == "application/pdf":
+		return err == nil && countTextChars(content) < ocrMinChars
+	default:
+		return false
+	}
+}
+
+func countTextChars(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// queueOCR menjadwalkan OCR tanpa menahan worker ekstraksi teks. Jika antrean
+// penuh, dokumen tetap berstatus pending dan bisa diproses lewat perintah
+// "extract-text".
+func queueOCR(documentID string) {
+	select {
+	case ocrQueue <- documentID:
+	default:
+		log.Printf("⚠️ Antrean OCR penuh, dokumen %s dilewati", documentID)
+	}
+}
+
+// StartOCRWorker memproses antrean OCR di background. Dokumen yang masih
+// pending dari proses sebelumnya (misalnya karena server restart) ikut
+// dijadwalkan ulang.
+func StartOCRWorker() {
+	for i := 0; i < ocrWorkers(); i++ {
+		go func() {
+			for documentID := range ocrQueue {
+				if err := ocrDocument(documentID); err != nil {
+					log.Printf("⚠️ Gagal menjalankan OCR dokumen %s: %v", documentID, err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		var documentIDs []string
+		if err := database.DB.Model(&DocumentText{}).
+			Joins("JOIN document_staffs ON document_staffs.id = document_texts.document_id AND document_staffs.public_id = document_texts.public_id").
+			Where("document_staffs.deleted_at IS NULL AND document_texts.status = ? AND document_texts.method = ?", TextPending, methodOCR).
+			Pluck("document_texts.document_id", &documentIDs).Error; err != nil {
+			log.Printf("⚠️ Gagal mengambil dokumen yang menunggu OCR: %v", err)
+			return
+		}
+
+		for _, documentID := range documentIDs {
+			ocrQueue <- documentID
+		}
+	}()
+}
+
+// ocrDocument menjalankan OCR pada file dokumen saat ini dan menyimpan
+// hasilnya di DocumentText beserta confidence dan lama pemrosesan.
+func ocrDocument(documentID string) error {
+	var document DocumentStaff
+	if err := database.DB.First(&document, "id = ?", documentID).Error; err != nil {
+		return err
+	}
+
+	if document.PublicID == "" {
+		return nil
+	}
+
+	start := time.Now()
+	result, err := ocrFile(document)
+	now := time.Now()
+
+	text := DocumentText{
+		DocumentID:  document.ID,
+		PublicID:    document.PublicID,
+		Method:      methodOCR,
+		ExtractedAt: &now,
+		DurationMs:  now.Sub(start).Milliseconds(),
+	}
+
+	switch {
+	case errors.Is(err, errNoExtractor):
+		text.Status = TextUnsupported
+		text.Error = err.Error()
+	case err != nil:
+		text.Status = TextFailed
+		text.Error = err.Error()
+	default:
+		text.Status = TextDone
+		text.Content, text.Truncated = truncateText(result.Text, textMaxChars())
+		text.Truncated = text.Truncated || result.PagesSkipped
+		text.CharCount = utf8.RuneCountInString(text.Content)
+		if result.Words > 0 {
+			confidence := result.Confidence()
+			text.Confidence = &confidence
+		}
+	}
+
+	saved, err := saveDocumentText(text)
+	if err != nil || !saved {
+		return err
+	}
+
+	syncSearchIndex(document.ID)
+	return nil
+}
+
+// ocrResult adalah gabungan hasil OCR seluruh halaman.
+type ocrResult struct {
+	Text         string
+	Words        int
+	TotalConf    float64
+	PagesSkipped bool
+}
+
+// Confidence adalah rata-rata confidence per kata (0-100).
+func (r ocrResult) Confidence() float64 {
+	if r.Words == 0 {
+		return 0
+	}
+
+	return r.TotalConf / float64(r.Words)
+}
+
+// ocrFile menyalin file ke direktori sementara lalu menjalankan Tesseract.
+// PDF dirender dulu per halaman dengan pdftoppm.
+func ocrFile(document DocumentStaff) (ocrResult, error) {
+	var result ocrResult
+
+	binary, err := exec.LookPath("tesseract")
+	if err != nil {
+		return result, fmt.Errorf("%w: tesseract tidak terpasang", errNoExtractor)
+	}
+
+	reader, err := store.Open(document.PublicID, document.ResourceType)
+	if err != nil {
+		return result, fmt.Errorf("file tidak dapat dibuka: %v", err)
+	}
+	defer reader.Close()
+
+	dir, err := os.MkdirTemp("", "ocr-*")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input")
+	f, err := os.Create(input)
+	if err != nil {
+		return result, err
+	}
+	if _, err := io.Copy(f, io.LimitReader(reader, maxUploadSize(document.ResourceType))); err != nil {
+		f.Close()
+		return result, fmt.Errorf("gagal membaca file: %v", err)
+	}
+	f.Close()
+
+	pages := []string{input}
+	dpi := 0
+	if documentMimeType(document) == "application/pdf" {
+		pages, result.PagesSkipped, err = renderPDFPages(input, dir)
+		if err != nil {
+			return result, err
+		}
+		dpi = ocrDPI
+	}
+
+	var out strings.Builder
+	for i, page := range pages {
+		text, words, totalConf, err := tesseractPage(binary, page, dpi)
+		if err != nil {
+			if len(pages) > 1 {
+				return result, fmt.Errorf("halaman %d: %v", i+1, err)
+			}
+			return result, err
+		}
+
+		if i > 0 {
+			out.WriteString("\n\n")
+		}
+		out.WriteString(text)
+		result.Words += words
+		result.TotalConf += totalConf
+	}
+
+	result.Text = normalizeText(out.String())
+	return result, nil
+}
+
+// renderPDFPages merender halaman PDF menjadi PNG grayscale, maksimal
+// ocrMaxPages halaman. Mengembalikan true jika ada halaman yang dilewati.
+func renderPDFPages(input, dir string) ([]string, bool, error) {
+	binary, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: pdftoppm tidak terpasang", errNoExtractor)
+	}
+
+	maxPages := ocrMaxPages()
+	skipped := false
+	if f, err := os.Open(input); err == nil {
+		if count, err := pdfbuild.PageCount(f); err == nil && count > maxPages {
+			skipped = true
+		}
+		f.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binary, "-r", strconv.Itoa(ocrDPI), "-gray", "-png",
+		"-f", "1", "-l", strconv.Itoa(maxPages), input, filepath.Join(dir, "page"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, false, fmt.Errorf("render PDF gagal: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	// pdftoppm memberi nomor halaman dengan nol di depan (page-01.png), jadi
+	// urutan nama file sama dengan urutan halaman.
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(pages) == 0 {
+		return nil, false, errors.New("PDF tidak memiliki halaman")
+	}
+	sort.Strings(pages)
+
+	return pages, skipped, nil
+}
+
+// tesseractPage menjalankan OCR satu gambar dengan keluaran TSV, yang berisi
+// teks per kata beserta confidence-nya.
+func tesseractPage(binary, image string, dpi int) (string, int, float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	args := []string{image, "stdout", "-l", ocrLanguages()}
+	if dpi > 0 {
+		args = append(args, "--dpi", strconv.Itoa(dpi))
+	}
+	args = append(args, "tsv")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	// Paralelisme diatur lewat OCR_WORKERS, bukan thread OpenMP Tesseract.
+	cmd.Env = append(os.Environ(), "OMP_THREAD_LIMIT=1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", 0, 0, fmt.Errorf("tesseract gagal: %s", message)
+		}
+		return "", 0, 0, fmt.Errorf("tesseract gagal: %v", err)
+	}
+
+	text, words, totalConf := parseTesseractTSV(&stdout)
+	return text, words, totalConf, nil
+}
+
+// parseTesseractTSV menyusun ulang teks dari baris level kata (level 5) TSV
+// Tesseract dan menjumlahkan confidence kata yang valid (conf >= 0).
+func parseTesseractTSV(r io.Reader) (string, int, float64) {
+	var out strings.Builder
+	words := 0
+	totalConf := 0.0
+	lastBlock, lastLine := "", ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// level page block par line word left top width height conf text
+		cols := strings.SplitN(scanner.Text(), "\t", 12)
+		if len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+
+		word := strings.TrimSpace(cols[11])
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		if word == "" || err != nil || conf < 0 {
+			continue
+		}
+
+		block := cols[1] + "/" + cols[2] + "/" + cols[3]
+		line := block + "/" + cols[4]
+		switch {
+		case out.Len() == 0:
+		case block != lastBlock:
+			out.WriteString("\n\n")
+		case line != lastLine:
+			out.WriteString("\n")
+		default:
+			out.WriteString(" ")
+		}
+		lastBlock, lastLine = block, line
+
+		out.WriteString(word)
+		words++
+		totalConf += conf
+	}
+
+	return out.String(), words, totalConf
+}
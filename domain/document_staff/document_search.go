@@ -0,0 +1,426 @@
+package document_staff
+
+import (
+	"errors"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// snippetRadius adalah jumlah karakter di sekitar kata yang cocok yang
+// ditampilkan pada cuplikan teks.
+const snippetRadius = 80
+
+// DocumentSearchEntry adalah salinan data dokumen aktif yang diindeks untuk
+// pencarian: metadata, nama pemilik, dan teks hasil ekstraksi. Dokumen di
+// trash tidak memiliki entri.
+type DocumentSearchEntry struct {
+	DocumentID     string    `gorm:"type:char(36);primaryKey" json:"document_id"`
+	UserID         string    `gorm:"type:char(36);index" json:"user_id"`
+	EmployeeID     string    `gorm:"type:char(36);index" json:"employee_id"`
+	DocumentTypeID string    `gorm:"type:char(36);index" json:"document_type_id"`
+	Subject        string    `gorm:"type:varchar(255);index:idx_document_search_text,class:FULLTEXT" json:"subject"`
+	FileName       string    `gorm:"type:varchar(500);index:idx_document_search_text,class:FULLTEXT" json:"file_name"`
+	OwnerName      string    `gorm:"type:varchar(255);index:idx_document_search_text,class:FULLTEXT" json:"owner_name"`
+	Content        string    `gorm:"type:longtext;index:idx_document_search_text,class:FULLTEXT" json:"-"`
+	ResourceType   string    `gorm:"type:varchar(20)" json:"resource_type"`
+	MimeType       string    `gorm:"type:varchar(100);index" json:"mime_type"`
+	UploadedAt     time.Time `gorm:"index" json:"uploaded_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// SearchQuery adalah kata kunci dan filter pencarian dokumen.
+type SearchQuery struct {
+	Text           string
+	UserID         string
+	EmployeeID     string
+	DocumentTypeID string
+	MimeType       string
+	ResourceType   string
+	StartDate      string
+	EndDate        string
+	Offset         int
+	Limit          int
+}
+
+// SearchHit adalah satu dokumen hasil pencarian beserta skornya.
+type SearchHit struct {
+	DocumentSearchEntry
+	Score float64
+}
+
+// SearchIndex adalah mesin pencarian dokumen. Implementasi bawaan memakai
+// FULLTEXT index MySQL; mesin lain cukup memenuhi interface ini.
+type SearchIndex interface {
+	Index(entry DocumentSearchEntry) error
+	Remove(documentID string) error
+	Search(query SearchQuery) ([]SearchHit, int64, error)
+}
+
+var searchIndex SearchIndex = mysqlSearchIndex{}
+
+// mysqlSearchIndex menyimpan entri di tabel document_search_entries dan
+// memakai MATCH ... AGAINST untuk peringkat. Kata yang lebih pendek dari
+// batas token FULLTEXT (default 3 karakter, misalnya "SK") tidak masuk index,
+// sehingga subject dan nama file juga dicocokkan dengan LIKE.
+type mysqlSearchIndex struct{}
+
+const searchMatch = "MATCH(subject, file_name, owner_name, content) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+func (mysqlSearchIndex) Index(entry DocumentSearchEntry) error {
+	return database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error
+}
+
+func (mysqlSearchIndex) Remove(documentID string) error {
+	return database.DB.Where("document_id = ?", documentID).Delete(&DocumentSearchEntry{}).Error
+}
+
+// likeEscaper meloloskan karakter khusus LIKE agar teks pencarian seperti
+// "50%" atau "SK_01" dicocokkan apa adanya. Backslash adalah karakter escape
+// bawaan LIKE di MySQL.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (mysqlSearchIndex) Search(q SearchQuery) ([]SearchHit, int64, error) {
+	like := "%" + likeEscaper.Replace(q.Text) + "%"
+
+	query := database.DB.Model(&DocumentSearchEntry{}).
+		Where(searchMatch+" > 0 OR subject LIKE ? OR file_name LIKE ?", q.Text, like, like)
+
+	if q.UserID != "" {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.EmployeeID != "" {
+		query = query.Where("employee_id = ?", q.EmployeeID)
+	}
+	if q.DocumentTypeID == "none" {
+		query = query.Where("document_type_id = ''")
+	} else if q.DocumentTypeID != "" {
+		query = query.Where("document_type_id = ?", q.DocumentTypeID)
+	}
+	if q.MimeType != "" {
+		query = query.Where("mime_type = ?", q.MimeType)
+	}
+	if q.ResourceType != "" {
+		query = query.Where("resource_type = ?", q.ResourceType)
+	}
+	if q.StartDate != "" {
+		query = query.Where("uploaded_at >= ?", q.StartDate)
+	}
+	if q.EndDate != "" {
+		query = query.Where("uploaded_at <= ?", q.EndDate)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []SearchHit
+	err := query.
+		Select("*, "+searchMatch+" + IF(subject LIKE ?, 1, 0) AS score", q.Text, like).
+		Order("score DESC, uploaded_at DESC").
+		Limit(q.Limit).
+		Offset(q.Offset).
+		Scan(&hits).Error
+
+	return hits, total, err
+}
+
+// syncSearchIndex menyamakan entri pencarian dengan kondisi dokumen saat ini:
+// dokumen aktif diindeks ulang, dokumen yang sudah di trash atau dihapus
+// dikeluarkan dari index. Teks hasil ekstraksi hanya dipakai jika berasal
+// dari file yang sedang aktif. Kegagalan hanya dicatat di log karena index
+// bisa dibangun ulang lewat perintah "reindex-search".
+func syncSearchIndex(documentID string) {
+	if err := indexDocument(documentID); err != nil {
+		log.Printf("⚠️ Gagal memperbarui index pencarian dokumen %s: %v", documentID, err)
+	}
+}
+
+func indexDocument(documentID string) error {
+	var document DocumentStaff
+	err := database.DB.First(&document, "id = ?", documentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return searchIndex.Remove(documentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := DocumentSearchEntry{
+		DocumentID:     document.ID,
+		UserID:         document.UserID,
+		EmployeeID:     document.EmployeeID,
+		DocumentTypeID: document.DocumentTypeID,
+		Subject:        document.Subject,
+		FileName:       document.FileName,
+		ResourceType:   document.ResourceType,
+		MimeType:       document.MimeType,
+		UploadedAt:     document.CreatedAt,
+	}
+
+	var ownerNames []string
+	if document.UserID != "" {
+		database.DB.Table("users").Where("id = ?", document.UserID).Limit(1).Pluck("name", &ownerNames)
+	} else if document.EmployeeID != "" {
+		database.DB.Table("employees").Where("id = ?", document.EmployeeID).Limit(1).Pluck("name", &ownerNames)
+	}
+	if len(ownerNames) > 0 {
+		entry.OwnerName = ownerNames[0]
+	}
+
+	var contents []string
+	if err := database.DB.Model(&DocumentText{}).
+		Where("document_id = ? AND public_id = ? AND status = ?", document.ID, document.PublicID, TextDone).
+		Limit(1).
+		Pluck("content", &contents).Error; err != nil {
+		return err
+	}
+	if len(contents) > 0 {
+		entry.Content = contents[0]
+	}
+
+	return searchIndex.Index(entry)
+}
+
+// ======================================================
+// SEARCH DOCUMENTS - FOR ALL ROLES
+// ======================================================
+// Mencari kata kunci q pada subject, nama file, nama pemilik, dan teks
+// dokumen. Admin mencari di semua dokumen dan boleh memakai filter user_id
+// dan employee_id; role lain hanya di dokumen miliknya.
+func SearchDocumentsStaff(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter q wajib diisi"})
+		return
+	}
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		pageInt = 1
+	}
+
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limitInt < 1 {
+		limitInt = 10
+	}
+
+	if limitInt > 50 {
+		limitInt = 50
+	}
+
+	query := SearchQuery{
+		Text:           text,
+		DocumentTypeID: c.Query("document_type_id"),
+		MimeType:       c.Query("mime_type"),
+		ResourceType:   c.Query("resource_type"),
+		StartDate:      c.Query("start_date"),
+		EndDate:        c.Query("end_date"),
+		Offset:         (pageInt - 1) * limitInt,
+		Limit:          limitInt,
+	}
+
+	role, employeeID := callerIdentity(c)
+	if isAdminRole(role) {
+		query.UserID = c.Query("user_id")
+		query.EmployeeID = c.Query("employee_id")
+	} else {
+		if employeeID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - employeeID not found"})
+			return
+		}
+		query.EmployeeID = employeeID
+	}
+
+	hits, total, err := searchIndex.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencari dokumen: " + err.Error()})
+		return
+	}
+
+	terms := searchTerms(text)
+	results := make([]gin.H, len(hits))
+	for i, hit := range hits {
+		results[i] = gin.H{
+			"id":               hit.DocumentID,
+			"user_id":          hit.UserID,
+			"employee_id":      hit.EmployeeID,
+			"document_type_id": hit.DocumentTypeID,
+			"subject":          hit.Subject,
+			"file_name":        hit.FileName,
+			"owner_name":       hit.OwnerName,
+			"mime_type":        hit.MimeType,
+			"resource_type":    hit.ResourceType,
+			"created_at":       hit.UploadedAt,
+			"score":            hit.Score,
+			"highlights": gin.H{
+				"subject":    highlightTerms(hit.Subject, terms),
+				"file_name":  highlightTerms(hit.FileName, terms),
+				"owner_name": highlightTerms(hit.OwnerName, terms),
+			},
+			"snippet": contentSnippet(hit.Content, terms),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mencari dokumen",
+		"data": gin.H{
+			"query":     text,
+			"documents": results,
+			"pagination": gin.H{
+				"current_page": pageInt,
+				"per_page":     limitInt,
+				"total_items":  total,
+				"total_pages":  int(math.Ceil(float64(total) / float64(limitInt))),
+			},
+		},
+	})
+}
+
+// searchTerms memecah kata kunci menjadi kata-kata (huruf kecil) untuk
+// penanda cuplikan.
+func searchTerms(text string) [][]rune {
+	var terms [][]rune
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, []rune(word))
+	}
+
+	return terms
+}
+
+// matchAt mengembalikan panjang kata kunci yang cocok di awal kata pada
+// posisi i (tanpa membedakan huruf besar/kecil), atau 0 jika tidak ada.
+func matchAt(lower []rune, i int, terms [][]rune) int {
+	if i > 0 && (unicode.IsLetter(lower[i-1]) || unicode.IsDigit(lower[i-1])) {
+		return 0
+	}
+
+	longest := 0
+	for _, term := range terms {
+		if len(term) <= longest || i+len(term) > len(lower) {
+			continue
+		}
+
+		match := true
+		for j, r := range term {
+			if lower[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			longest = len(term)
+		}
+	}
+
+	return longest
+}
+
+// highlightTerms meng-escape HTML pada teks lalu membungkus setiap kata kunci
+// yang ditemukan dengan <mark>.
+func highlightTerms(text string, terms [][]rune) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var out strings.Builder
+	start := 0
+	for i := 0; i < len(runes); {
+		n := matchAt(lower, i, terms)
+		if n == 0 {
+			i++
+			continue
+		}
+
+		out.WriteString(html.EscapeString(string(runes[start:i])))
+		out.WriteString("<mark>")
+		out.WriteString(html.EscapeString(string(runes[i : i+n])))
+		out.WriteString("</mark>")
+		i += n
+		start = i
+	}
+	out.WriteString(html.EscapeString(string(runes[start:])))
+
+	return out.String()
+}
+
+// contentSnippet mengambil potongan teks di sekitar kata kunci pertama yang
+// ditemukan. Jika tidak ada yang cocok (misalnya hanya subject yang cocok),
+// awal teks yang dipakai.
+func contentSnippet(content string, terms [][]rune) string {
+	if content == "" {
+		return ""
+	}
+
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	first := -1
+	for i := range lower {
+		if matchAt(lower, i, terms) > 0 {
+			first = i
+			break
+		}
+	}
+
+	start, end := 0, min(len(runes), 2*snippetRadius)
+	if first >= 0 {
+		start = max(0, first-snippetRadius)
+		end = min(len(runes), first+snippetRadius)
+	}
+
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+
+	return highlightTerms(snippet, terms)
+}
+
+// ReindexSearch membangun ulang index pencarian untuk semua dokumen,
+// termasuk mengeluarkan dokumen di trash. Mengembalikan jumlah dokumen yang
+// diproses.
+func ReindexSearch() (int, error) {
+	var documentIDs []string
+	if err := database.DB.Unscoped().Model(&DocumentStaff{}).Pluck("id", &documentIDs).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, documentID := range documentIDs {
+		if err := indexDocument(documentID); err != nil {
+			log.Printf("⚠️ Gagal mengindeks dokumen %s: %v", documentID, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
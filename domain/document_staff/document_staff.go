@@ -12,22 +12,34 @@ import (
 )
 
 type DocumentStaff struct {
-	ID           string            `gorm:"type:char(36);primaryKey" json:"id"`
-	UserID       string            `gorm:"type:char(36);null;default:null" json:"user_id"`
-	EmployeeID   string            `gorm:"type:char(36);null;default:null" json:"employee_id"`
-	FileURL      string            `gorm:"type:text" json:"file_url"`
-	User         user.User         `gorm:"foreignKey:UserID;references:ID" json:"user"`
-	Employee     employee.Employee `gorm:"foreignKey:EmployeeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"employee,omitempty"`
-	Subject      string            `gorm:"type:varchar(255)" json:"subject"`
-	FileName     string            `gorm:"type:varchar(500)" json:"file_name"`
-	PublicID     string            `gorm:"type:varchar(255)" json:"public_id"`
-	ResourceType string            `gorm:"type:varchar(20)" json:"resource_type"`
-	CreatedAt    time.Time         `json:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at"`
+	ID                string            `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID            string            `gorm:"type:char(36);null;default:null" json:"user_id"`
+	EmployeeID        string            `gorm:"type:char(36);null;default:null" json:"employee_id"`
+	FileURL           string            `gorm:"type:text" json:"file_url"`
+	User              user.User         `gorm:"foreignKey:UserID;references:ID" json:"user"`
+	Employee          employee.Employee `gorm:"foreignKey:EmployeeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"employee,omitempty"`
+	DocumentTypeID    string            `gorm:"type:char(36);null;default:null;index" json:"document_type_id"`
+	Subject           string            `gorm:"type:varchar(255)" json:"subject"`
+	FileName          string            `gorm:"type:varchar(500)" json:"file_name"`
+	PublicID          string            `gorm:"type:varchar(255)" json:"public_id"`
+	ResourceType      string            `gorm:"type:varchar(20)" json:"resource_type"`
+	MimeType          string            `gorm:"type:varchar(100)" json:"mime_type"`
+	Checksum          string            `gorm:"type:char(64)" json:"checksum"`
+	Size              int64             `json:"size"`
+	OriginalSize      int64             `json:"original_size"`
+	OriginalChecksum  string            `gorm:"type:char(64)" json:"original_checksum"`
+	OriginalPublicID  string            `gorm:"type:varchar(255)" json:"original_public_id,omitempty"`
+	UploadedBy        string            `gorm:"type:char(36)" json:"uploaded_by"`
+	ThumbnailPublicID string            `gorm:"type:varchar(255)" json:"thumbnail_public_id"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt    `gorm:"index" json:"deleted_at,omitempty"`
 }
 
 func (d *DocumentStaff) BeforeCreate(tx *gorm.DB) (err error) {
-	d.ID = uuid.NewString()
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
 	return
 }
 
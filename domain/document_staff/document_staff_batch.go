@@ -0,0 +1,278 @@
+package document_staff
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/domain/storage_quota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// spooledFile adalah part "files" yang sudah ditampung di disk.
+type spooledFile struct {
+	FileName string
+	Path     string
+	Size     int64
+	TooLarge bool
+}
+
+// batchUploadResult adalah hasil upload satu file dalam request multi-file.
+type batchUploadResult struct {
+	Index    int            `json:"index"`
+	FileName string         `json:"file_name"`
+	Subject  string         `json:"subject"`
+	Status   int            `json:"status"`
+	Document *DocumentStaff `json:"document,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// maxFilesPerRequest diatur lewat env UPLOAD_MAX_FILES (default 20).
+func maxFilesPerRequest() int {
+	if n, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_FILES")); err == nil && n > 0 {
+		return n
+	}
+
+	return 20
+}
+
+// uploadWorkers adalah jumlah upload ke storage yang berjalan bersamaan,
+// diatur lewat env UPLOAD_WORKERS (default 4).
+func uploadWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv("UPLOAD_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+
+	return 4
+}
+
+// spoolLimit adalah batas ukuran terbesar dari semua resource type. Batas per
+// jenis file tetap diterapkan saveFile setelah isi file diperiksa.
+func spoolLimit() int64 {
+	limit := maxUploadSize("raw")
+	if image := maxUploadSize("image"); image > limit {
+		limit = image
+	}
+
+	return limit
+}
+
+// spoolPart menampung satu part ke file sementara. Part yang melewati batas
+// tetap dibaca sampai habis agar part berikutnya bisa diproses, tetapi isinya
+// dibuang.
+func spoolPart(part *multipart.Part) (*spooledFile, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	spooled := &spooledFile{FileName: filepath.Base(part.FileName()), Path: tmp.Name()}
+	limit := spoolLimit()
+
+	n, err := io.Copy(tmp, io.LimitReader(part, limit+1))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if n > limit {
+		spooled.TooLarge = true
+		if _, err := io.Copy(io.Discard, part); err != nil {
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		tmp.Truncate(0)
+		n = 0
+	}
+
+	spooled.Size = n
+	return spooled, nil
+}
+
+// cleanup menghapus file sementara hasil spoolPart.
+func (f *uploadForm) cleanup() {
+	for _, spooled := range f.spooled {
+		os.Remove(spooled.Path)
+	}
+	f.spooled = nil
+}
+
+// createDocumentsBatch menyimpan setiap file dari part "files" sebagai dokumen
+// terpisah. Subject diambil dari field "subjects" sesuai urutan file, atau
+// dari "subject" (atau nama jenis dokumen) jika tidak ada. Kegagalan satu
+// file tidak membatalkan file lain. Untuk jenis dokumen yang unik per
+// pegawai, hanya file pertama yang diterima.
+func createDocumentsBatch(c *gin.Context, employeeID string, form *uploadForm, docType *document_type.DocumentType) {
+	results := make([]batchUploadResult, len(form.spooled))
+	subjects := form.fields["subjects"]
+
+	usage, err := storage_quota.GetUsage(employeeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa kuota penyimpanan: " + err.Error()})
+		return
+	}
+
+	var jobs []int
+	for i, spooled := range form.spooled {
+		subject := form.fields.Get("subject")
+		if i < len(subjects) && subjects[i] != "" {
+			subject = subjects[i]
+		}
+		subject = documentSubject(subject, docType)
+
+		results[i] = batchUploadResult{Index: i, FileName: spooled.FileName, Subject: subject}
+
+		switch {
+		case subject == "":
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "Subject atau jenis dokumen wajib diisi"
+		case spooled.TooLarge:
+			results[i].Status = http.StatusRequestEntityTooLarge
+			results[i].Error = fmt.Sprintf("Ukuran file melebihi batas %d MB", spoolLimit()>>20)
+		case docType != nil && docType.UniquePerEmployee && len(jobs) > 0:
+			results[i].Status = http.StatusConflict
+			results[i].Error = fmt.Sprintf("Jenis dokumen %s hanya boleh satu per pegawai", docType.Name)
+		default:
+			if err := checkDocumentType(docType, spooled.FileName, employeeID, ""); err != nil {
+				results[i].Status = uploadErrorStatus(err)
+				results[i].Error = err.Error()
+				continue
+			}
+
+			if err := usage.Allow(spooled.Size, 1); err != nil {
+				results[i].Status = http.StatusRequestEntityTooLarge
+				results[i].Error = err.Error()
+				continue
+			}
+
+			usage.TotalBytes += spooled.Size
+			usage.DocumentCount++
+			jobs = append(jobs, i)
+		}
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := uploadWorkers()
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				saveBatchFile(employeeID, docType, form.spooled[i], &results[i])
+			}
+		}()
+	}
+
+	for _, i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Document != nil {
+			succeeded++
+		}
+	}
+
+	status := http.StatusCreated
+	message := "Semua dokumen berhasil diupload"
+	switch {
+	case succeeded == 0:
+		status = http.StatusBadRequest
+		message = "Tidak ada dokumen yang berhasil diupload"
+	case succeeded < len(results):
+		status = http.StatusMultiStatus
+		message = fmt.Sprintf("%d dari %d dokumen berhasil diupload", succeeded, len(results))
+	}
+
+	c.JSON(status, gin.H{
+		"message":   message,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+// saveBatchFile mengupload satu file dan menyimpan barisnya. Jika file
+// melebihi batas jenis dokumen atau penyimpanan ke database gagal, file di
+// storage dijadwalkan untuk dihapus.
+func saveBatchFile(employeeID string, docType *document_type.DocumentType, spooled *spooledFile, result *batchUploadResult) {
+	f, err := os.Open(spooled.Path)
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Error = "File sementara tidak dapat dibuka: " + err.Error()
+		return
+	}
+
+	file, err := saveFile(f, spooled.FileName, employeeID)
+	f.Close()
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		if uploadErr, ok := err.(*uploadError); ok {
+			result.Status = uploadErr.status
+		}
+		result.Error = err.Error()
+		return
+	}
+
+	form := &uploadForm{file: file}
+
+	if err := checkDocumentTypeSize(docType, file.Size); err != nil {
+		form.discard()
+		result.Status = uploadErrorStatus(err)
+		result.Error = err.Error()
+		return
+	}
+
+	documentID := uuid.NewString()
+	document := DocumentStaff{
+		ID:               documentID,
+		EmployeeID:       employeeID,
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          result.Subject,
+		FileName:         file.FileName,
+		FileURL:          fileURL(documentID, file.Object),
+		PublicID:         file.Object.PublicID,
+		ResourceType:     file.ResourceType,
+		MimeType:         file.MimeType,
+		Checksum:         file.Checksum,
+		Size:             file.Size,
+		OriginalSize:     file.OriginalSize,
+		OriginalChecksum: file.OriginalChecksum,
+		OriginalPublicID: file.OriginalPublicID,
+		UploadedBy:       employeeID,
+	}
+
+	if err := createDocument(&document, docType); err != nil {
+		form.discard()
+		result.Status = uploadErrorStatus(err)
+		result.Error = err.Error()
+		if _, ok := err.(*uploadError); !ok {
+			result.Error = "Gagal menyimpan dokumen: " + err.Error()
+		}
+		return
+	}
+
+	queueFileProcessing(document.ID)
+
+	signed := withSignedURL(document)
+	result.Status = http.StatusCreated
+	result.Document = &signed
+}
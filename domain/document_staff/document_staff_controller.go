@@ -1,125 +1,134 @@
 package document_staff
 
 import (
-	"bytes"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
-	"BackendKantorDinsos/infrastructure/config"
 	"BackendKantorDinsos/infrastructure/database"
 
 	"BackendKantorDinsos/domain/employee"
 	"BackendKantorDinsos/domain/user"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ======================================================
 // CREATE DOCUMENT STAFF - ADMIN ONLY
 // ======================================================
+// Field teks multipart (subject, user_id, employee_id, document_type_id)
+// harus dikirim sebelum part file.
 func CreateDocumentStaffAdmin(c *gin.Context) {
+	validate := func(fields url.Values, fileName string) (string, error) {
+		userID := fields.Get("user_id")
+		employeeID := fields.Get("employee_id")
 
-	userID := c.PostForm("user_id")
-	employeeID := c.PostForm("employee_id")
-	subject := c.PostForm("subject")
+		if fields.Get("subject") == "" && fields.Get("document_type_id") == "" {
+			return "", fmt.Errorf("Subject atau jenis dokumen wajib diisi")
+		}
 
-	if subject == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject wajib diisi"})
-		return
-	}
+		if userID == "" && employeeID == "" {
+			return "", fmt.Errorf("UserID atau EmployeeID harus diisi")
+		}
 
-	if userID == "" && employeeID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "UserID atau EmployeeID harus diisi"})
-		return
-	}
+		if userID != "" {
+			var user user.User
+			if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+				return "", fmt.Errorf("UserID tidak ditemukan")
+			}
+		}
 
-	fileHeader, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
-		return
-	}
+		if employeeID != "" {
+			var employee employee.Employee
+			if err := database.DB.First(&employee, "id = ?", employeeID).Error; err != nil {
+				return "", fmt.Errorf("EmployeeID tidak ditemukan")
+			}
+		}
 
-	src, err := fileHeader.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tidak dapat membuka file"})
-		return
-	}
-	defer src.Close()
+		docType, err := documentTypeFromFields(fields)
+		if err != nil {
+			return "", err
+		}
 
-	fileBytes, err := io.ReadAll(src)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file"})
-		return
+		if err := checkDocumentType(docType, fileName, employeeID, ""); err != nil {
+			return "", err
+		}
+
+		return ownerID(userID, employeeID), nil
 	}
-	reader := bytes.NewReader(fileBytes)
 
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-	var resourceType, folder string
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
-		resourceType = "image"
-		folder = "gambar"
-	case ".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx":
-		resourceType = "raw"
-		folder = "document_staff"
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Format file tidak didukung"})
+	form, ok := streamUpload(c, validate)
+	if !ok {
 		return
 	}
 
-	if userID != "" {
-		var user user.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "UserID tidak ditemukan"})
+	if form.file == nil {
+		if _, err := validate(form.fields, ""); err != nil {
+			respondUploadError(c, err)
 			return
 		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
+		return
 	}
 
-	if employeeID != "" {
-		var employee employee.Employee
-		if err := database.DB.First(&employee, "id = ?", employeeID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "EmployeeID tidak ditemukan"})
-			return
-		}
+	docType, err := documentTypeFromFields(form.fields)
+	if err == nil {
+		err = checkDocumentTypeSize(docType, form.file.Size)
 	}
-
-	uploadResult, err := config.UploadToCloudinary(reader, fileHeader.Filename, folder, resourceType)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload gagal: " + err.Error()})
+		form.discard()
+		respondUploadError(c, err)
 		return
 	}
 
+	documentID := uuid.NewString()
 	document := DocumentStaff{
-		UserID:       userID,
-		EmployeeID:   employeeID,
-		Subject:      subject,
-		FileName:     fileHeader.Filename,
-		FileURL:      uploadResult.SecureURL,
-		PublicID:     uploadResult.PublicID,
-		ResourceType: resourceType,
-	}
-
-	if err := database.DB.Create(&document).Error; err != nil {
-		config.DeleteFromCloudinary(uploadResult.PublicID, resourceType)
+		ID:               documentID,
+		UserID:           form.fields.Get("user_id"),
+		EmployeeID:       form.fields.Get("employee_id"),
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          documentSubject(form.fields.Get("subject"), docType),
+		FileName:         form.file.FileName,
+		FileURL:          fileURL(documentID, form.file.Object),
+		PublicID:         form.file.Object.PublicID,
+		ResourceType:     form.file.ResourceType,
+		MimeType:         form.file.MimeType,
+		Checksum:         form.file.Checksum,
+		Size:             form.file.Size,
+		OriginalSize:     form.file.OriginalSize,
+		OriginalChecksum: form.file.OriginalChecksum,
+		OriginalPublicID: form.file.OriginalPublicID,
+		UploadedBy:       c.GetString("employeeID"),
+	}
+
+	if err := createDocument(&document, docType); err != nil {
+		form.discard()
+		if _, ok := err.(*uploadError); ok {
+			respondUploadError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error: " + err.Error()})
 		return
 	}
 
+	queueFileProcessing(document.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":  "Dokumen berhasil dibuat",
-		"document": document,
+		"document": withSignedURL(document),
 	})
 }
 
 // ======================================================
 // CREATE DOCUMENT STAFF - FOR LOGGED IN USER
 // ======================================================
+// Field teks multipart (subject, user_id, employee_id, document_type_id)
+// harus dikirim sebelum part file.
 func CreateDocumentStaff(c *gin.Context) {
 	employeeIDRaw, exists := c.Get("employeeID")
 	if !exists {
@@ -139,71 +148,112 @@ func CreateDocumentStaff(c *gin.Context) {
 		return
 	}
 
-	subject := c.PostForm("subject")
-	if subject == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject wajib diisi"})
+	if !precheckQuota(c, employeeID, 1) {
 		return
 	}
 
-	fileHeader, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
+	form, ok := streamMultiUpload(c, func(fields url.Values, fileName string) (string, error) {
+		if fields.Get("subject") == "" && fields.Get("document_type_id") == "" {
+			return "", fmt.Errorf("Subject atau jenis dokumen wajib diisi")
+		}
+
+		docType, err := documentTypeFromFields(fields)
+		if err != nil {
+			return "", err
+		}
+
+		if err := checkDocumentType(docType, fileName, employeeID, ""); err != nil {
+			return "", err
+		}
+
+		return employeeID, nil
+	})
+	if !ok {
 		return
 	}
+	defer form.cleanup()
 
-	src, err := fileHeader.Open()
+	docType, err := documentTypeFromFields(form.fields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tidak dapat membuka file"})
+		form.discard()
+		respondUploadError(c, err)
 		return
 	}
-	defer src.Close()
 
-	fileBytes, err := io.ReadAll(src)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file"})
+	if len(form.spooled) > 0 {
+		if form.file != nil {
+			form.discard()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gunakan field file atau files, tidak keduanya"})
+			return
+		}
+
+		// mode=pdf menggabungkan semua gambar menjadi satu dokumen PDF.
+		if form.fields.Get("mode") == "pdf" {
+			createCombinedPDF(c, employeeID, form, docType)
+			return
+		}
+
+		createDocumentsBatch(c, employeeID, form, docType)
 		return
 	}
-	reader := bytes.NewReader(fileBytes)
 
-	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-	var resourceType, folder string
+	subject := documentSubject(form.fields.Get("subject"), docType)
+	if subject == "" {
+		form.discard()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject atau jenis dokumen wajib diisi"})
+		return
+	}
 
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
-		resourceType = "image"
-		folder = "gambar"
-	case ".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx":
-		resourceType = "raw"
-		folder = "document_staff"
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Format file tidak didukung"})
+	if form.file == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak ditemukan"})
 		return
 	}
 
-	uploadResult, err := config.UploadToCloudinary(reader, fileHeader.Filename, folder, resourceType)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload gagal: " + err.Error()})
+	if err := checkDocumentTypeSize(docType, form.file.Size); err != nil {
+		form.discard()
+		respondUploadError(c, err)
 		return
 	}
 
-	document := DocumentStaff{
-		EmployeeID:   employeeID,
-		Subject:      subject,
-		FileName:     fileHeader.Filename,
-		FileURL:      uploadResult.SecureURL,
-		PublicID:     uploadResult.PublicID,
-		ResourceType: resourceType,
+	if !checkQuota(c, employeeID, form.file.Size, 1) {
+		form.discard()
+		return
 	}
 
-	if err := database.DB.Create(&document).Error; err != nil {
-		config.DeleteFromCloudinary(uploadResult.PublicID, resourceType)
+	documentID := uuid.NewString()
+	document := DocumentStaff{
+		ID:               documentID,
+		EmployeeID:       employeeID,
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          subject,
+		FileName:         form.file.FileName,
+		FileURL:          fileURL(documentID, form.file.Object),
+		PublicID:         form.file.Object.PublicID,
+		ResourceType:     form.file.ResourceType,
+		MimeType:         form.file.MimeType,
+		Checksum:         form.file.Checksum,
+		Size:             form.file.Size,
+		OriginalSize:     form.file.OriginalSize,
+		OriginalChecksum: form.file.OriginalChecksum,
+		OriginalPublicID: form.file.OriginalPublicID,
+		UploadedBy:       employeeID,
+	}
+
+	if err := createDocument(&document, docType); err != nil {
+		form.discard()
+		if _, ok := err.(*uploadError); ok {
+			respondUploadError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan dokumen: " + err.Error()})
 		return
 	}
 
+	queueFileProcessing(document.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":  "Dokumen berhasil diupload",
-		"document": document,
+		"document": withSignedURL(document),
 	})
 }
 
@@ -213,11 +263,6 @@ func CreateDocumentStaff(c *gin.Context) {
 func GetAllDocumentsStaffAdmin(c *gin.Context) {
 	page := c.DefaultQuery("page", "1")
 	limit := c.DefaultQuery("limit", "20")
-	subject := c.Query("subject")
-	userID := c.Query("user_id")
-	employeeID := c.Query("employee_id")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
 
 	pageInt, err := strconv.Atoi(page)
 	if err != nil || pageInt < 1 {
@@ -244,6 +289,13 @@ func GetAllDocumentsStaffAdmin(c *gin.Context) {
 				document_staffs.file_name,
 				document_staffs.public_id,
 				document_staffs.resource_type,
+				document_staffs.mime_type,
+				document_staffs.size,
+				document_staffs.original_size,
+				document_staffs.document_type_id,
+				document_types.code AS document_type_code,
+				document_types.name AS document_type_name,
+				document_staffs.thumbnail_public_id,
 				document_staffs.created_at,
 				document_staffs.updated_at,
 				CASE 
@@ -252,49 +304,49 @@ func GetAllDocumentsStaffAdmin(c *gin.Context) {
 					ELSE ''
 				END as owner_name`).
 		Joins("LEFT JOIN users ON users.id = document_staffs.user_id").
-		Joins("LEFT JOIN employees ON employees.id = document_staffs.employee_id")
-
-	if subject != "" {
-		query = query.Where("document_staffs.subject LIKE ?", "%"+subject+"%")
-	}
-
-	if userID != "" {
-		query = query.Where("document_staffs.user_id = ?", userID)
-	}
-
-	if employeeID != "" {
-		query = query.Where("document_staffs.employee_id = ?", employeeID)
-	}
+		Joins("LEFT JOIN employees ON employees.id = document_staffs.employee_id").
+		Joins("LEFT JOIN document_types ON document_types.id = document_staffs.document_type_id")
 
-	if startDate != "" {
-		query = query.Where("document_staffs.created_at >= ?", startDate)
-	}
-
-	if endDate != "" {
-		query = query.Where("document_staffs.created_at <= ?", endDate)
-	}
+	query = applyAdminDocumentFilters(c, query)
 
 	var total int64
 	query.Count(&total)
 
+	var groups []documentTypeGroup
+	order := "document_staffs.created_at DESC"
+	if groupByDocumentType(c) {
+		if groups, err = documentTypeGroups(query); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengelompokkan dokumen: " + err.Error()})
+			return
+		}
+		order = "document_types.name IS NULL, document_types.name ASC, " + order
+	}
+
 	type DocumentStaffResponse struct {
-		ID           string    `json:"id"`
-		UserID       *string   `json:"user_id,omitempty"`
-		EmployeeID   *string   `json:"employee_id,omitempty"`
-		FileURL      string    `json:"file_url"`
-		Subject      string    `json:"subject"`
-		FileName     string    `json:"file_name"`
-		PublicID     string    `json:"public_id"`
-		ResourceType string    `json:"resource_type"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		OwnerName    string    `json:"owner_name"`
+		ID                string    `json:"id"`
+		UserID            *string   `json:"user_id,omitempty"`
+		EmployeeID        *string   `json:"employee_id,omitempty"`
+		FileURL           string    `json:"file_url"`
+		Subject           string    `json:"subject"`
+		FileName          string    `json:"file_name"`
+		PublicID          string    `json:"public_id"`
+		ResourceType      string    `json:"resource_type"`
+		MimeType          string    `json:"mime_type"`
+		Size              int64     `json:"size"`
+		OriginalSize      int64     `json:"original_size"`
+		DocumentTypeID    *string   `json:"-"`
+		DocumentTypeCode  *string   `json:"-"`
+		DocumentTypeName  *string   `json:"-"`
+		ThumbnailPublicID string    `json:"-"`
+		CreatedAt         time.Time `json:"created_at"`
+		UpdatedAt         time.Time `json:"updated_at"`
+		OwnerName         string    `json:"owner_name"`
 	}
 
 	var documents []DocumentStaffResponse
 
 	if err := query.
-		Order("document_staffs.created_at DESC").
+		Order(order).
 		Limit(limitInt).
 		Offset(offset).
 		Scan(&documents).Error; err != nil {
@@ -308,11 +360,16 @@ func GetAllDocumentsStaffAdmin(c *gin.Context) {
 	for i, doc := range documents {
 		formattedDoc := map[string]interface{}{
 			"id":            doc.ID,
-			"file_url":      doc.FileURL,
+			"file_url":      resolveFileURL(doc.FileURL, doc.PublicID, doc.ResourceType),
 			"subject":       doc.Subject,
 			"file_name":     doc.FileName,
 			"public_id":     doc.PublicID,
 			"resource_type": doc.ResourceType,
+			"mime_type":     doc.MimeType,
+			"size":          doc.Size,
+			"original_size": doc.OriginalSize,
+			"document_type": documentTypeSummary(doc.DocumentTypeID, doc.DocumentTypeCode, doc.DocumentTypeName),
+			"thumbnail_url": thumbnailURL(doc.ID, doc.ThumbnailPublicID),
 			"created_at":    doc.CreatedAt,
 			"updated_at":    doc.UpdatedAt,
 			"owner_name":    doc.OwnerName,
@@ -334,20 +391,52 @@ func GetAllDocumentsStaffAdmin(c *gin.Context) {
 
 	totalPages := int(math.Ceil(float64(total) / float64(limitInt)))
 
+	data := gin.H{
+		"documents": formattedDocuments,
+		"pagination": gin.H{
+			"current_page": pageInt,
+			"per_page":     limitInt,
+			"total_items":  total,
+			"total_pages":  totalPages,
+		},
+	}
+	if groups != nil {
+		data["groups"] = groups
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Berhasil mengambil data dokumen staff",
-		"data": gin.H{
-			"documents": formattedDocuments,
-			"pagination": gin.H{
-				"current_page": pageInt,
-				"per_page":     limitInt,
-				"total_items":  total,
-				"total_pages":  totalPages,
-			},
-		},
+		"data":    data,
 	})
 }
 
+// applyAdminDocumentFilters menerapkan filter listing admin (subject,
+// user_id, employee_id, start_date, end_date, document_type_id) pada query
+// document_staffs.
+func applyAdminDocumentFilters(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if subject := c.Query("subject"); subject != "" {
+		query = query.Where("document_staffs.subject LIKE ?", "%"+subject+"%")
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("document_staffs.user_id = ?", userID)
+	}
+
+	if employeeID := c.Query("employee_id"); employeeID != "" {
+		query = query.Where("document_staffs.employee_id = ?", employeeID)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("document_staffs.created_at >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("document_staffs.created_at <= ?", endDate)
+	}
+
+	return applyDocumentTypeFilter(c, query)
+}
+
 // ======================================================
 // GET MY DOCUMENTS STAFF - FOR LOGGED IN USER
 // ======================================================
@@ -402,10 +491,18 @@ func GetMyDocumentsStaff(c *gin.Context) {
 				document_staffs.file_name,
 				document_staffs.public_id,
 				document_staffs.resource_type,
+				document_staffs.mime_type,
+				document_staffs.size,
+				document_staffs.original_size,
+				document_staffs.document_type_id,
+				document_types.code AS document_type_code,
+				document_types.name AS document_type_name,
+				document_staffs.thumbnail_public_id,
 				document_staffs.created_at,
 				document_staffs.updated_at,
 				employees.name as owner_name`).
 		Joins("LEFT JOIN employees ON employees.id = document_staffs.employee_id").
+		Joins("LEFT JOIN document_types ON document_types.id = document_staffs.document_type_id").
 		Where("document_staffs.employee_id = ?", employeeID)
 
 	if subject != "" {
@@ -420,26 +517,45 @@ func GetMyDocumentsStaff(c *gin.Context) {
 		query = query.Where("document_staffs.created_at <= ?", endDate)
 	}
 
+	query = applyDocumentTypeFilter(c, query)
+
 	var total int64
 	query.Count(&total)
 
+	var groups []documentTypeGroup
+	order := "document_staffs.created_at DESC"
+	if groupByDocumentType(c) {
+		if groups, err = documentTypeGroups(query); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengelompokkan dokumen: " + err.Error()})
+			return
+		}
+		order = "document_types.name IS NULL, document_types.name ASC, " + order
+	}
+
 	type MyDocumentResponse struct {
-		ID           string    `json:"id"`
-		EmployeeID   string    `json:"employee_id"`
-		FileURL      string    `json:"file_url"`
-		Subject      string    `json:"subject"`
-		FileName     string    `json:"file_name"`
-		PublicID     string    `json:"public_id"`
-		ResourceType string    `json:"resource_type"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		OwnerName    string    `json:"owner_name"`
+		ID                string    `json:"id"`
+		EmployeeID        string    `json:"employee_id"`
+		FileURL           string    `json:"file_url"`
+		Subject           string    `json:"subject"`
+		FileName          string    `json:"file_name"`
+		PublicID          string    `json:"public_id"`
+		ResourceType      string    `json:"resource_type"`
+		MimeType          string    `json:"mime_type"`
+		Size              int64     `json:"size"`
+		OriginalSize      int64     `json:"original_size"`
+		DocumentTypeID    *string   `json:"-"`
+		DocumentTypeCode  *string   `json:"-"`
+		DocumentTypeName  *string   `json:"-"`
+		ThumbnailPublicID string    `json:"-"`
+		CreatedAt         time.Time `json:"created_at"`
+		UpdatedAt         time.Time `json:"updated_at"`
+		OwnerName         string    `json:"owner_name"`
 	}
 
 	var documents []MyDocumentResponse
 
 	if err := query.
-		Order("document_staffs.created_at DESC").
+		Order(order).
 		Limit(limitInt).
 		Offset(offset).
 		Scan(&documents).Error; err != nil {
@@ -453,11 +569,16 @@ func GetMyDocumentsStaff(c *gin.Context) {
 	for i, doc := range documents {
 		formattedDoc := map[string]interface{}{
 			"id":            doc.ID,
-			"file_url":      doc.FileURL,
+			"file_url":      resolveFileURL(doc.FileURL, doc.PublicID, doc.ResourceType),
 			"subject":       doc.Subject,
 			"file_name":     doc.FileName,
 			"public_id":     doc.PublicID,
 			"resource_type": doc.ResourceType,
+			"mime_type":     doc.MimeType,
+			"size":          doc.Size,
+			"original_size": doc.OriginalSize,
+			"document_type": documentTypeSummary(doc.DocumentTypeID, doc.DocumentTypeCode, doc.DocumentTypeName),
+			"thumbnail_url": thumbnailURL(doc.ID, doc.ThumbnailPublicID),
 			"created_at":    doc.CreatedAt,
 			"updated_at":    doc.UpdatedAt,
 			"owner_name":    doc.OwnerName,
@@ -469,38 +590,32 @@ func GetMyDocumentsStaff(c *gin.Context) {
 
 	totalPages := int(math.Ceil(float64(total) / float64(limitInt)))
 
+	data := gin.H{
+		"documents": formattedDocuments,
+		"pagination": gin.H{
+			"current_page": pageInt,
+			"per_page":     limitInt,
+			"total_items":  total,
+			"total_pages":  totalPages,
+		},
+	}
+	if groups != nil {
+		data["groups"] = groups
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Berhasil mengambil data dokumen Anda",
-		"data": gin.H{
-			"documents": formattedDocuments,
-			"pagination": gin.H{
-				"current_page": pageInt,
-				"per_page":     limitInt,
-				"total_items":  total,
-				"total_pages":  totalPages,
-			},
-		},
+		"data":    data,
 	})
 }
 
 // ======================================================
 // UPDATE FOR ADMIN ONLY
 // ======================================================
+// Field teks multipart (subject, user_id, employee_id, document_type_id)
+// harus dikirim sebelum part file.
 func UpdateDocumentStaffAdmin(c *gin.Context) {
 	documentID := c.Param("id")
-	userID := c.PostForm("user_id")
-	employeeID := c.PostForm("employee_id")
-	subject := c.PostForm("subject")
-
-	if subject == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject wajib diisi"})
-		return
-	}
-
-	if userID == "" && employeeID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "UserID atau EmployeeID harus diisi"})
-		return
-	}
 
 	var document DocumentStaff
 	if err := database.DB.First(&document, "id = ?", documentID).Error; err != nil {
@@ -508,78 +623,131 @@ func UpdateDocumentStaffAdmin(c *gin.Context) {
 		return
 	}
 
-	if document.PublicID != "" {
-		err := config.DeleteFromCloudinary(document.PublicID, document.ResourceType)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus dokumen lama: " + err.Error()})
-			return
+	// Pemilik dokumen setelah update, untuk aturan jenis dokumen unik.
+	ownerEmployeeID := func(fields url.Values) string {
+		if employeeID := fields.Get("employee_id"); employeeID != "" {
+			return employeeID
 		}
+		return document.EmployeeID
 	}
 
-	fileHeader, err := c.FormFile("file")
-	if err == nil {
-		src, err := fileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Tidak dapat membuka file"})
-			return
+	validate := func(fields url.Values, fileName string) (string, error) {
+		if fields.Get("subject") == "" {
+			return "", fmt.Errorf("Subject wajib diisi")
 		}
-		defer src.Close()
 
-		fileBytes, err := io.ReadAll(src)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file"})
-			return
+		if fields.Get("user_id") == "" && fields.Get("employee_id") == "" {
+			return "", fmt.Errorf("UserID atau EmployeeID harus diisi")
 		}
-		reader := bytes.NewReader(fileBytes)
-
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		var resourceType, folder string
-		switch ext {
-		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
-			resourceType = "image"
-			folder = "gambar"
-		case ".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx":
-			resourceType = "raw"
-			folder = "document_staff"
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Format file tidak didukung"})
-			return
+
+		if fileName != "" {
+			docType, err := effectiveDocumentType(fields, document.DocumentTypeID)
+			if err != nil {
+				return "", err
+			}
+
+			if err := checkDocumentType(docType, fileName, ownerEmployeeID(fields), document.ID); err != nil {
+				return "", err
+			}
 		}
 
-		uploadResult, err := config.UploadToCloudinary(reader, fileHeader.Filename, folder, resourceType)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload gagal: " + err.Error()})
+		return ownerID(fields.Get("user_id"), fields.Get("employee_id")), nil
+	}
+
+	form, ok := streamUpload(c, validate)
+	if !ok {
+		return
+	}
+
+	if _, err := validate(form.fields, ""); err != nil {
+		form.discard()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	docType, err := checkDocumentTypeUpdate(form.fields, form.file, document, ownerEmployeeID(form.fields))
+	if err != nil {
+		form.discard()
+		respondUploadError(c, err)
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := claimDocumentType(tx, docType, ownerEmployeeID(form.fields), document.ID); err != nil {
+		tx.Rollback()
+		form.discard()
+		respondUploadError(c, err)
+		return
+	}
+
+	if form.file != nil {
+		if err := archiveCurrentFile(tx, document); err != nil {
+			tx.Rollback()
+			form.discard()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan versi dokumen lama: " + err.Error()})
 			return
 		}
 
-		document.FileName = fileHeader.Filename
-		document.FileURL = uploadResult.SecureURL
-		document.PublicID = uploadResult.PublicID
-		document.ResourceType = resourceType
+		document.FileName = form.file.FileName
+		document.FileURL = fileURL(document.ID, form.file.Object)
+		document.PublicID = form.file.Object.PublicID
+		document.ResourceType = form.file.ResourceType
+		document.MimeType = form.file.MimeType
+		document.Checksum = form.file.Checksum
+		document.Size = form.file.Size
+		document.OriginalSize = form.file.OriginalSize
+		document.OriginalChecksum = form.file.OriginalChecksum
+		document.OriginalPublicID = form.file.OriginalPublicID
+		document.UploadedBy = c.GetString("employeeID")
+
+		if err := resetThumbnail(tx, &document); err != nil {
+			tx.Rollback()
+			form.discard()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
+			return
+		}
 	}
 
-	document.Subject = subject
-	if userID != "" {
+	document.Subject = form.fields.Get("subject")
+	document.DocumentTypeID = documentTypeID(docType)
+	if userID := form.fields.Get("user_id"); userID != "" {
 		document.UserID = userID
 	}
-	if employeeID != "" {
+	if employeeID := form.fields.Get("employee_id"); employeeID != "" {
 		document.EmployeeID = employeeID
 	}
 
-	if err := database.DB.Save(&document).Error; err != nil {
+	if err := tx.Save(&document).Error; err != nil {
+		tx.Rollback()
+		form.discard()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		form.discard()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
 		return
 	}
 
+	if form.file != nil {
+		queueFileProcessing(document.ID)
+	} else {
+		syncSearchIndex(document.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "Dokumen berhasil diperbarui",
-		"document": document,
+		"document": withSignedURL(document),
 	})
 }
 
 // ======================================================
 // UPDATE MY DOCUMENT - FOR LOGGED IN EMPLOYEE
 // ======================================================
+// Field teks multipart (subject, user_id, employee_id, document_type_id)
+// harus dikirim sebelum part file.
 func UpdateMyDocumentStaff(c *gin.Context) {
 	employeeIDRaw, exists := c.Get("employeeID")
 	if !exists {
@@ -599,15 +767,49 @@ func UpdateMyDocumentStaff(c *gin.Context) {
 		return
 	}
 
-	subject := c.PostForm("subject")
+	var document DocumentStaff
+	if err := database.DB.First(&document, "id = ? AND employee_id = ?", documentID, employeeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan atau Anda tidak memiliki akses"})
+		return
+	}
+
+	form, ok := streamUpload(c, func(fields url.Values, fileName string) (string, error) {
+		if fields.Get("subject") == "" {
+			return "", fmt.Errorf("Subject wajib diisi")
+		}
+
+		docType, err := effectiveDocumentType(fields, document.DocumentTypeID)
+		if err != nil {
+			return "", err
+		}
+
+		if err := checkDocumentType(docType, fileName, employeeID, document.ID); err != nil {
+			return "", err
+		}
+
+		// Kuota hanya diperiksa jika file baru dikirim; update subject atau
+		// jenis dokumen saja tetap diizinkan.
+		if err := precheckQuotaError(c, employeeID, 0); err != nil {
+			return "", err
+		}
+
+		return employeeID, nil
+	})
+	if !ok {
+		return
+	}
+
+	subject := form.fields.Get("subject")
 	if subject == "" {
+		form.discard()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject wajib diisi"})
 		return
 	}
 
-	var document DocumentStaff
-	if err := database.DB.First(&document, "id = ? AND employee_id = ?", documentID, employeeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan atau Anda tidak memiliki akses"})
+	docType, err := checkDocumentTypeUpdate(form.fields, form.file, document, employeeID)
+	if err != nil {
+		form.discard()
+		respondUploadError(c, err)
 		return
 	}
 
@@ -617,68 +819,80 @@ func UpdateMyDocumentStaff(c *gin.Context) {
 		"updated_at":  time.Now(),
 	}
 
-	fileHeader, err := c.FormFile("file")
-	if err == nil {
-		if document.PublicID != "" {
-			err := config.DeleteFromCloudinary(document.PublicID, document.ResourceType)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus dokumen lama: " + err.Error()})
-				return
-			}
-		}
+	fieldsToUpdate := []string{"subject", "employee_id", "updated_at"}
 
-		src, err := fileHeader.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Tidak dapat membuka file"})
-			return
-		}
-		defer src.Close()
+	if docType != nil {
+		updates["document_type_id"] = docType.ID
+		fieldsToUpdate = append(fieldsToUpdate, "document_type_id")
+	}
 
-		fileBytes, err := io.ReadAll(src)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file"})
-			return
-		}
-		reader := bytes.NewReader(fileBytes)
-
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		var resourceType, folder string
-		switch ext {
-		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
-			resourceType = "image"
-			folder = "gambar"
-		case ".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx":
-			resourceType = "raw"
-			folder = "document_staff"
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Format file tidak didukung"})
+	// File lama diarsipkan sebagai versi, jadi file baru menambah pemakaian.
+	if form.file != nil && !checkQuota(c, employeeID, form.file.Size, 0) {
+		form.discard()
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	if err := claimDocumentType(tx, docType, employeeID, document.ID); err != nil {
+		tx.Rollback()
+		form.discard()
+		respondUploadError(c, err)
+		return
+	}
+
+	if form.file != nil {
+		if err := archiveCurrentFile(tx, document); err != nil {
+			tx.Rollback()
+			form.discard()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan versi dokumen lama: " + err.Error()})
 			return
 		}
 
-		uploadResult, err := config.UploadToCloudinary(reader, fileHeader.Filename, folder, resourceType)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload gagal: " + err.Error()})
+		updates["file_name"] = form.file.FileName
+		updates["file_url"] = fileURL(document.ID, form.file.Object)
+		updates["public_id"] = form.file.Object.PublicID
+		updates["resource_type"] = form.file.ResourceType
+		updates["mime_type"] = form.file.MimeType
+		updates["checksum"] = form.file.Checksum
+		updates["size"] = form.file.Size
+		updates["original_size"] = form.file.OriginalSize
+		updates["original_checksum"] = form.file.OriginalChecksum
+		updates["original_public_id"] = form.file.OriginalPublicID
+		updates["uploaded_by"] = employeeID
+
+		if err := resetThumbnail(tx, &document); err != nil {
+			tx.Rollback()
+			form.discard()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
 			return
 		}
+		updates["thumbnail_public_id"] = ""
 
-		updates["file_name"] = fileHeader.Filename
-		updates["file_url"] = uploadResult.SecureURL
-		updates["public_id"] = uploadResult.PublicID
-		updates["resource_type"] = resourceType
-	}
-
-	fieldsToUpdate := []string{"subject", "employee_id", "updated_at"}
-	if fileHeader != nil {
-		fieldsToUpdate = append(fieldsToUpdate, "file_name", "file_url", "public_id", "resource_type")
+		fieldsToUpdate = append(fieldsToUpdate, "file_name", "file_url", "public_id", "resource_type", "mime_type", "checksum", "size", "original_size", "original_checksum", "original_public_id", "uploaded_by", "thumbnail_public_id")
 	}
 
-	if err := database.DB.Model(&document).
+	if err := tx.Model(&document).
 		Select(fieldsToUpdate).
 		Updates(updates).Error; err != nil {
+		tx.Rollback()
+		form.discard()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		form.discard()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui dokumen: " + err.Error()})
 		return
 	}
 
+	if form.file != nil {
+		queueFileProcessing(document.ID)
+	} else {
+		syncSearchIndex(document.ID)
+	}
+
 	if err := database.DB.First(&document, "id = ?", documentID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dokumen terbaru: " + err.Error()})
 		return
@@ -686,7 +900,7 @@ func UpdateMyDocumentStaff(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "Dokumen berhasil diperbarui",
-		"document": document,
+		"document": withSignedURL(document),
 	})
 }
 
@@ -700,60 +914,118 @@ func DeleteDocumentStaff(c *gin.Context) {
 		return
 	}
 
-	roleRaw, exists := c.Get("role")
-	var role string
-	if exists {
-		if r, ok := roleRaw.(string); ok {
-			role = r
-		}
+	document, ok := findAccessibleDocument(c, documentID)
+	if !ok {
+		return
 	}
 
-	employeeIDRaw, employeeExists := c.Get("employeeID")
-	var employeeID string
-	if employeeExists {
-		if eID, ok := employeeIDRaw.(string); ok {
-			employeeID = eID
-		}
+	// Dokumen hanya dipindahkan ke trash; file dan barisnya dihapus permanen
+	// oleh purger setelah masa retensi habis.
+	if err := database.DB.Delete(&document).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus dokumen: " + err.Error()})
+		return
+	}
+
+	syncSearchIndex(document.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Dokumen berhasil dipindahkan ke trash",
+		"data": gin.H{
+			"id":         document.ID,
+			"file_name":  document.FileName,
+			"subject":    document.Subject,
+			"deleted_at": time.Now(),
+			"purge_at":   time.Now().Add(trashRetention()),
+		},
+	})
+}
+
+// ======================================================
+// DOWNLOAD DOCUMENT STAFF - FOR ALL ROLES
+// ======================================================
+func DownloadDocumentStaff(c *gin.Context) {
+	documentID := c.Param("id")
+	if documentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID wajib diisi"})
+		return
+	}
+
+	document, ok := findAccessibleDocument(c, documentID)
+	if !ok {
+		return
+	}
+
+	if document.PublicID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak memiliki file"})
+		return
 	}
 
+	serveStoredFile(c, storedFile{
+		PublicID:     document.PublicID,
+		ResourceType: document.ResourceType,
+		FileName:     document.FileName,
+		MimeType:     document.MimeType,
+		Checksum:     document.Checksum,
+		ModTime:      document.UpdatedAt,
+		OnOpen: func() {
+			recordAccess(c, document.ID, "", accessDownload)
+		},
+	})
+}
+
+// findAccessibleDocument mengambil dokumen dengan aturan akses yang sama untuk
+// semua role: admin boleh semua dokumen, selain admin hanya dokumen miliknya.
+// Jika gagal, response error sudah dikirim.
+func findAccessibleDocument(c *gin.Context, documentID string) (DocumentStaff, bool) {
+	return findDocumentForCaller(c, database.DB, documentID)
+}
+
+func findDocumentForCaller(c *gin.Context, db *gorm.DB, documentID string) (DocumentStaff, bool) {
+	role, employeeID := callerIdentity(c)
+
 	var document DocumentStaff
-	query := database.DB.Where("id = ?", documentID)
+	query := db.Where("id = ?", documentID)
 
-	if role != "admin" && role != "superadmin" {
+	if !isAdminRole(role) {
 		if employeeID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - employeeID not found"})
-			return
+			return document, false
 		}
 		query = query.Where("employee_id = ?", employeeID)
 	}
 
 	if err := query.First(&document).Error; err != nil {
-		if role == "admin" || role == "superadmin" {
+		if isAdminRole(role) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan"})
 		} else {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan atau Anda tidak memiliki akses"})
 		}
-		return
+		return document, false
 	}
 
-	if document.PublicID != "" {
-		if err := config.DeleteFromCloudinary(document.PublicID, document.ResourceType); err != nil {
-			fmt.Printf("Warning: Failed to delete file from Cloudinary: %v\n", err)
+	return document, true
+}
+
+func callerIdentity(c *gin.Context) (string, string) {
+	roleRaw, exists := c.Get("role")
+	var role string
+	if exists {
+		if r, ok := roleRaw.(string); ok {
+			role = r
 		}
 	}
 
-	if err := database.DB.Delete(&document).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus dokumen: " + err.Error()})
-		return
+	employeeIDRaw, employeeExists := c.Get("employeeID")
+	var employeeID string
+	if employeeExists {
+		if eID, ok := employeeIDRaw.(string); ok {
+			employeeID = eID
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Dokumen berhasil dihapus",
-		"data": gin.H{
-			"id":         document.ID,
-			"file_name":  document.FileName,
-			"subject":    document.Subject,
-			"deleted_at": time.Now(),
-		},
-	})
+	return role, employeeID
+}
+
+func isAdminRole(role string) bool {
+	return role == "admin" || role == "superadmin"
 }
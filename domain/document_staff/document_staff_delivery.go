@@ -0,0 +1,128 @@
+package document_staff
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// legacyFileURLPattern mencocokkan file_url publik Cloudinary yang disimpan
+// sebelum mode delivery privat dipakai.
+const legacyFileURLPattern = "https://res.cloudinary.com/%/upload/%"
+
+// ======================================================
+// GET SIGNED FILE URL - OWNER OR ADMIN
+// ======================================================
+func GetDocumentStaffURL(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	recordAccess(c, document.ID, "", accessURL)
+
+	signer, ok := store.(storage.Signer)
+	if !ok || document.PublicID == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Berhasil mengambil URL dokumen",
+			"url":        document.FileURL,
+			"expires_at": nil,
+		})
+		return
+	}
+
+	ttl := storage.URLTTL()
+	expiresAt := time.Now().Add(ttl)
+
+	url, err := signer.SignedURL(document.PublicID, document.ResourceType, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat URL dokumen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Berhasil mengambil URL dokumen",
+		"url":        url,
+		"expires_at": expiresAt,
+	})
+}
+
+// DeliveryMigrationReport berisi hasil migrasi file publik lama ke mode
+// delivery privat.
+type DeliveryMigrationReport struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Migrated   int             `json:"migrated"`
+	Items      []ReconcileItem `json:"items"`
+}
+
+// MigratePrivateDelivery memindahkan file dokumen (termasuk yang ada di trash)
+// dan file versi lama ke tipe delivery privat, lalu mengganti file_url publik
+// dengan endpoint download yang terautentikasi. Aman dijalankan ulang karena
+// hanya baris dengan file_url publik yang diproses.
+func MigratePrivateDelivery() (DeliveryMigrationReport, error) {
+	report := DeliveryMigrationReport{StartedAt: time.Now(), Items: []ReconcileItem{}}
+
+	privatizer, ok := store.(storage.Privatizer)
+	if !ok {
+		return report, fmt.Errorf("storage yang dipakai tidak mendukung migrasi delivery privat")
+	}
+
+	var documents []DocumentStaff
+	if err := database.DB.Unscoped().
+		Where("public_id <> '' AND file_url LIKE ?", legacyFileURLPattern).
+		Find(&documents).Error; err != nil {
+		return report, err
+	}
+
+	for _, document := range documents {
+		item := ReconcileItem{PublicID: document.PublicID, ResourceType: document.ResourceType, DocumentID: document.ID}
+
+		object, err := privatizer.MakePrivate(document.PublicID, document.ResourceType)
+		if err == nil {
+			err = database.DB.Unscoped().Model(&document).
+				Update("file_url", fileURL(document.ID, object)).Error
+		}
+
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Repaired = true
+			report.Migrated++
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	var versions []DocumentVersion
+	if err := database.DB.
+		Where("public_id <> '' AND file_url LIKE ?", legacyFileURLPattern).
+		Find(&versions).Error; err != nil {
+		return report, err
+	}
+
+	for _, version := range versions {
+		item := ReconcileItem{PublicID: version.PublicID, ResourceType: version.ResourceType, DocumentID: version.DocumentID, VersionID: version.ID}
+
+		object, err := privatizer.MakePrivate(version.PublicID, version.ResourceType)
+		if err == nil {
+			err = database.DB.Model(&version).
+				Update("file_url", fileURL(version.DocumentID, object)).Error
+		}
+
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Repaired = true
+			report.Migrated++
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
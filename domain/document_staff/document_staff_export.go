@@ -0,0 +1,274 @@
+package document_staff
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const accessExport = "export"
+
+// exportMaxDocuments membatasi jumlah dokumen dalam satu arsip ZIP, diatur
+// lewat env EXPORT_MAX_DOCUMENTS (default 1000).
+func exportMaxDocuments() int {
+	if n, err := strconv.Atoi(os.Getenv("EXPORT_MAX_DOCUMENTS")); err == nil && n > 0 {
+		return n
+	}
+
+	return 1000
+}
+
+// exportEntry adalah satu baris manifest arsip.
+type exportEntry struct {
+	ID           string    `json:"id"`
+	Subject      string    `json:"subject"`
+	OwnerID      string    `json:"owner_id"`
+	OwnerName    string    `json:"owner_name"`
+	FileName     string    `json:"file_name"`
+	Path         string    `json:"path"`
+	MimeType     string    `json:"mime_type"`
+	Size         int64     `json:"size"`
+	Checksum     string    `json:"checksum"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	Error        string    `json:"error,omitempty"`
+	PublicID     string    `json:"-"`
+	ResourceType string    `json:"-"`
+}
+
+// ======================================================
+// EXPORT DOCUMENTS AS ZIP - ADMIN ONLY
+// ======================================================
+// Menerima filter yang sama dengan GetAllDocumentsStaffAdmin atau daftar
+// ids (dipisah koma atau diulang). Arsip ditulis langsung ke response
+// sehingga pemakaian memori tidak bergantung pada ukuran total file.
+func ExportDocumentsStaffAdmin(c *gin.Context) {
+	query := database.DB.Model(&DocumentStaff{}).
+		Select(`document_staffs.id,
+				document_staffs.subject,
+				document_staffs.file_name,
+				document_staffs.public_id,
+				document_staffs.resource_type,
+				document_staffs.mime_type,
+				document_staffs.size,
+				document_staffs.checksum,
+				document_staffs.created_at AS uploaded_at,
+				COALESCE(NULLIF(document_staffs.employee_id, ''), document_staffs.user_id, '') AS owner_id,
+				CASE
+					WHEN document_staffs.user_id IS NOT NULL AND document_staffs.user_id != '' THEN users.name
+					WHEN document_staffs.employee_id IS NOT NULL AND document_staffs.employee_id != '' THEN employees.name
+					ELSE ''
+				END as owner_name`).
+		Joins("LEFT JOIN users ON users.id = document_staffs.user_id").
+		Joins("LEFT JOIN employees ON employees.id = document_staffs.employee_id")
+
+	if ids := exportIDs(c); len(ids) > 0 {
+		query = query.Where("document_staffs.id IN ?", ids)
+	} else {
+		query = applyAdminDocumentFilters(c, query)
+	}
+
+	maxDocuments := exportMaxDocuments()
+
+	var entries []exportEntry
+	if err := query.
+		Order("owner_name ASC, document_staffs.created_at ASC").
+		Limit(maxDocuments + 1).
+		Scan(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dokumen: " + err.Error()})
+		return
+	}
+
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tidak ada dokumen yang cocok dengan filter"})
+		return
+	}
+
+	if len(entries) > maxDocuments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Terlalu banyak dokumen, maksimal %d per arsip. Persempit filter", maxDocuments)})
+		return
+	}
+
+	archiveName := "dokumen-" + time.Now().Format("20060102-150405") + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, archiveName))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	usedPaths := map[string]bool{}
+
+	for i := range entries {
+		entry := &entries[i]
+		entry.Path = uniqueArchivePath(usedPaths, entry.OwnerName, entry.FileName)
+
+		if err := writeArchiveFile(archive, entry); err != nil {
+			// Status sudah terkirim; kegagalan satu file dicatat di manifest
+			// agar arsip tetap bisa dipakai.
+			log.Printf("⚠️ Gagal menambahkan dokumen %s ke arsip: %v", entry.ID, err)
+			entry.Error = err.Error()
+			if isClientGone(c) {
+				return
+			}
+			continue
+		}
+
+		recordAccess(c, entry.ID, "", accessExport)
+	}
+
+	if err := writeManifests(archive, entries); err != nil {
+		log.Printf("⚠️ Gagal menulis manifest arsip: %v", err)
+		return
+	}
+
+	if err := archive.Close(); err != nil {
+		log.Printf("⚠️ Gagal menutup arsip: %v", err)
+	}
+}
+
+// exportIDs membaca parameter ids, baik diulang (?ids=a&ids=b) maupun
+// dipisah koma (?ids=a,b).
+func exportIDs(c *gin.Context) []string {
+	var ids []string
+	for _, value := range c.QueryArray("ids") {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+func writeArchiveFile(archive *zip.Writer, entry *exportEntry) error {
+	reader, err := store.Open(entry.PublicID, entry.ResourceType)
+	if err != nil {
+		return fmt.Errorf("file tidak dapat dibuka: %v", err)
+	}
+	defer reader.Close()
+
+	header := &zip.FileHeader{
+		Name:     entry.Path,
+		Method:   archiveMethod(entry.MimeType),
+		Modified: entry.UploadedAt,
+	}
+
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("gagal membaca file: %v", err)
+	}
+
+	return nil
+}
+
+func writeManifests(archive *zip.Writer, entries []exportEntry) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return err
+	}
+
+	w, err = archive.CreateHeader(&zip.FileHeader{Name: "manifest.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "subject", "owner_id", "owner_name", "file_name", "path", "mime_type", "size", "checksum", "uploaded_at", "error"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.ID,
+			entry.Subject,
+			entry.OwnerID,
+			entry.OwnerName,
+			entry.FileName,
+			entry.Path,
+			entry.MimeType,
+			strconv.FormatInt(entry.Size, 10),
+			entry.Checksum,
+			entry.UploadedAt.Format(time.RFC3339),
+			entry.Error,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// archiveMethod tidak mengompresi ulang format yang sudah terkompresi.
+func archiveMethod(mimeType string) uint16 {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"),
+		strings.HasPrefix(mimeType, "video/"),
+		strings.HasPrefix(mimeType, "audio/"),
+		strings.Contains(mimeType, "zip"),
+		strings.Contains(mimeType, "openxmlformats"):
+		return zip.Store
+	default:
+		return zip.Deflate
+	}
+}
+
+// uniqueArchivePath menyusun path "<pemilik>/<nama file>" dan menambahkan
+// akhiran " (2)", " (3)", ... jika nama yang sama sudah dipakai.
+func uniqueArchivePath(used map[string]bool, ownerName, fileName string) string {
+	folder := sanitizeArchiveName(ownerName)
+	if folder == "" {
+		folder = "tanpa-pemilik"
+	}
+
+	name := sanitizeArchiveName(fileName)
+	if name == "" {
+		name = "dokumen"
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := folder + "/" + name
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s/%s (%d)%s", folder, base, n, ext)
+	}
+
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func sanitizeArchiveName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+
+	return strings.Trim(strings.TrimSpace(name), ".")
+}
+
+func isClientGone(c *gin.Context) bool {
+	return c.Request.Context().Err() != nil
+}
@@ -0,0 +1,363 @@
+package document_staff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"BackendKantorDinsos/infrastructure/clamav"
+	"BackendKantorDinsos/infrastructure/filecheck"
+	"BackendKantorDinsos/infrastructure/storage"
+
+	"golang.org/x/image/draw"
+)
+
+const originalFolder = "original"
+
+// imageNormalization aktif kecuali env IMAGE_NORMALIZE bernilai "false".
+func imageNormalization() bool {
+	return !strings.EqualFold(strings.TrimSpace(os.Getenv("IMAGE_NORMALIZE")), "false")
+}
+
+// imageMaxSide adalah sisi terpanjang gambar setelah diperkecil, diatur lewat
+// env IMAGE_MAX_SIDE (default 2480 piksel, kira-kira A4 pada 300 dpi).
+func imageMaxSide() int {
+	if n, err := strconv.Atoi(os.Getenv("IMAGE_MAX_SIDE")); err == nil && n > 0 {
+		return n
+	}
+
+	return 2480
+}
+
+// imageMaxPixels membatasi jumlah piksel gambar upload yang mau didecode,
+// diatur lewat env IMAGE_MAX_PIXELS (default 25 juta piksel). Gambar didecode
+// utuh ke RGBA (4 byte per piksel) di setiap worker upload, jadi batas ini
+// jauh di bawah batas thumbnail agar memori tetap terkendali saat beberapa
+// upload berjalan bersamaan.
+func imageMaxPixels() int {
+	if n, err := strconv.Atoi(os.Getenv("IMAGE_MAX_PIXELS")); err == nil && n > 0 {
+		return n
+	}
+
+	return 25_000_000
+}
+
+// imageJPEGQuality diatur lewat env IMAGE_JPEG_QUALITY (1-100, default 85).
+func imageJPEGQuality() int {
+	if n, err := strconv.Atoi(os.Getenv("IMAGE_JPEG_QUALITY")); err == nil && n >= 1 && n <= 100 {
+		return n
+	}
+
+	return 85
+}
+
+// keepOriginalImage menyimpan file asli di folder "original" jika env
+// IMAGE_KEEP_ORIGINAL bernilai "true", misalnya untuk kebutuhan arsip.
+func keepOriginalImage() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("IMAGE_KEEP_ORIGINAL")), "true")
+}
+
+// normalizedImage adalah hasil normalizeImage.
+type normalizedImage struct {
+	Data     []byte
+	MimeType string
+	FileName string
+}
+
+// normalizeImage memutar gambar sesuai orientasi EXIF, memperkecilnya ke
+// imageMaxSide, lalu meng-encode ulang. Encode ulang sekaligus membuang
+// seluruh metadata (lokasi GPS, perangkat, dsb). JPEG dan WebP disimpan
+// sebagai JPEG, PNG tetap PNG. GIF dikembalikan apa adanya (ok false) agar
+// animasinya tidak hilang.
+func normalizeImage(data []byte, mimeType, fileName string) (normalizedImage, bool, error) {
+	if mimeType == "image/gif" {
+		return normalizedImage{}, false, nil
+	}
+
+	img, err := decodeImage(bytes.NewReader(data), imageMaxPixels())
+	if err != nil {
+		return normalizedImage{}, false, err
+	}
+
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	opaque := mimeType != "image/png"
+	img = orientImage(resizeToFit(img, imageMaxSide(), opaque), orientation)
+
+	var buf bytes.Buffer
+	result := normalizedImage{FileName: fileName}
+
+	if opaque {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJPEGQuality()}); err != nil {
+			return normalizedImage{}, false, err
+		}
+		result.MimeType = "image/jpeg"
+		if ext := strings.ToLower(filepath.Ext(fileName)); ext != ".jpg" && ext != ".jpeg" {
+			result.FileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".jpg"
+		}
+	} else {
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return normalizedImage{}, false, err
+		}
+		result.MimeType = "image/png"
+	}
+
+	result.Data = buf.Bytes()
+	return result, true, nil
+}
+
+// resizeToFit memperkecil gambar (tidak pernah memperbesar) sehingga sisi
+// terpanjangnya maxSide. Dipakai untuk normalisasi upload maupun thumbnail.
+// Gambar opaque digambar di atas latar putih agar piksel transparan tidak
+// menjadi hitam saat disimpan sebagai JPEG.
+func resizeToFit(img image.Image, maxSide int, opaque bool) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(1, height*maxSide/width)
+			width = maxSide
+		} else {
+			width = max(1, width*maxSide/height)
+			height = maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	op := draw.Src
+	if opaque {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, op)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, op, nil)
+	}
+
+	return dst
+}
+
+// orientImage menerapkan nilai tag Orientation EXIF (1-8) sehingga gambar
+// tampil tegak tanpa bergantung pada metadata.
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // cermin horizontal
+				dx, dy = w-1-x, y
+			case 3: // putar 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // cermin vertikal
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // putar 90 searah jarum jam
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // putar 90 berlawanan jarum jam
+				dx, dy = y, w-1-x
+			}
+
+			si := src.PixOffset(x+src.Rect.Min.X, y+src.Rect.Min.Y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation membaca tag Orientation (0x0112) dari segmen APP1 Exif
+// sebuah JPEG. Mengembalikan 1 jika tag tidak ada atau tidak terbaca.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 1
+		}
+
+		marker := data[pos+1]
+		if marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			pos += 2
+			continue
+		}
+
+		// SOS: data gambar dimulai, tidak ada metadata lagi.
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+
+		pos += 2 + length
+	}
+
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		// tag 0x0112 bertipe SHORT (3) dengan nilai di 2 byte pertama
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+
+	return 1
+}
+
+// saveNormalizedImage adalah jalur saveFile untuk gambar. Gambar perlu
+// di-decode utuh, sehingga isinya ditampung di memori (dibatasi
+// maxUploadSize) dan dipindai lebih dulu sebelum versi yang sudah
+// dinormalisasi disimpan ke storage.
+func saveNormalizedImage(inspector *filecheck.Inspector, limited *limitedReader, scan *clamav.Stream, fileName, folder, owner string) (*uploadedFile, error) {
+	var sink io.Writer = io.Discard
+	if scan != nil {
+		sink = scan
+	}
+
+	data, err := io.ReadAll(io.TeeReader(limited, sink))
+	if limited.exceeded {
+		return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Ukuran file melebihi batas %d MB", maxUploadSize("image")>>20)}
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, "Gagal membaca file: " + err.Error()}
+	}
+
+	if err := inspector.Verdict(); err != nil {
+		return nil, &uploadError{http.StatusBadRequest, inspectionMessage(err)}
+	}
+
+	if err := finishScan(scan, fileName, owner); err != nil {
+		return nil, err
+	}
+
+	normalized, ok, err := normalizeImage(data, inspector.MimeType(), fileName)
+	if errors.Is(err, errImageTooLarge) {
+		return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Resolusi gambar melebihi batas %d megapiksel", imageMaxPixels()/1_000_000)}
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, "Gambar tidak dapat diproses: " + err.Error()}
+	}
+	if !ok {
+		normalized = normalizedImage{Data: data, MimeType: inspector.MimeType(), FileName: fileName}
+	}
+
+	var original storage.Object
+	if ok && keepOriginalImage() {
+		original, err = store.Put(bytes.NewReader(data), storage.PutOptions{
+			FileName:     fileName,
+			Folder:       originalFolder,
+			ResourceType: "image",
+			OwnerID:      owner,
+		})
+		if err != nil {
+			return nil, &uploadError{http.StatusInternalServerError, "Upload gagal: " + err.Error()}
+		}
+	}
+
+	object, err := store.Put(bytes.NewReader(normalized.Data), storage.PutOptions{
+		FileName:     normalized.FileName,
+		Folder:       folder,
+		ResourceType: "image",
+		OwnerID:      owner,
+	})
+	if err != nil {
+		if original.PublicID != "" {
+			scheduleStorageDeletion(original.PublicID, "image")
+		}
+		return nil, &uploadError{http.StatusInternalServerError, "Upload gagal: " + err.Error()}
+	}
+
+	checksum := sha256.Sum256(normalized.Data)
+	originalChecksum := sha256.Sum256(data)
+
+	return &uploadedFile{
+		FileName:         normalized.FileName,
+		ResourceType:     "image",
+		MimeType:         normalized.MimeType,
+		Checksum:         hex.EncodeToString(checksum[:]),
+		Object:           object,
+		Size:             int64(len(normalized.Data)),
+		OriginalSize:     int64(len(data)),
+		OriginalChecksum: hex.EncodeToString(originalChecksum[:]),
+		OriginalPublicID: original.PublicID,
+	}, nil
+}
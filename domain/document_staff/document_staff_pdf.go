@@ -0,0 +1,533 @@
+package document_staff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/filecheck"
+	"BackendKantorDinsos/infrastructure/pdfbuild"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tempPDF membuat file sementara untuk hasil konversi PDF. File dihapus oleh
+// removeTempFiles.
+func tempPDF() (*os.File, error) {
+	return os.CreateTemp("", "pdf-*.pdf")
+}
+
+func removeTempFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// imagePagePDF mengubah satu gambar menjadi PDF satu halaman. Gambar lebih
+// dulu dinormalisasi (orientasi EXIF, ukuran, metadata) seperti upload gambar
+// biasa. Karena piksel di-encode ulang, isi tersembunyi di file gambar asli
+// tidak ikut masuk ke PDF.
+func imagePagePDF(data []byte, mimeType, fileName string) (*os.File, error) {
+	normalized, ok, err := normalizeImage(data, mimeType, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		data = normalized.Data
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := tempPDF()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pdfbuild.ImageToPDF(page, bytes.NewReader(data), config.Width > config.Height); err != nil {
+		removeTempFiles([]*os.File{page})
+		return nil, err
+	}
+
+	if _, err := page.Seek(0, io.SeekStart); err != nil {
+		removeTempFiles([]*os.File{page})
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// mergePDFs menggabungkan halaman-halaman PDF ke file sementara baru yang
+// sudah di-seek ke awal.
+func mergePDFs(parts []*os.File) (*os.File, error) {
+	inputs := make([]io.ReadSeeker, len(parts))
+	for i, part := range parts {
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		inputs[i] = part
+	}
+
+	merged, err := tempPDF()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pdfbuild.Merge(merged, inputs); err != nil {
+		removeTempFiles([]*os.File{merged})
+		return nil, err
+	}
+
+	if _, err := merged.Seek(0, io.SeekStart); err != nil {
+		removeTempFiles([]*os.File{merged})
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// pageOrder membaca field page_order berisi indeks file (mulai 0) yang
+// dipisah koma, misalnya "2,0,1". Tanpa page_order, urutan upload dipakai.
+func pageOrder(value string, count int) ([]int, error) {
+	order := make([]int, 0, count)
+	if strings.TrimSpace(value) == "" {
+		for i := 0; i < count; i++ {
+			order = append(order, i)
+		}
+		return order, nil
+	}
+
+	seen := make([]bool, count)
+	for _, item := range strings.Split(value, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || index < 0 || index >= count {
+			return nil, fmt.Errorf("page_order berisi indeks tidak valid: %s", strings.TrimSpace(item))
+		}
+		if seen[index] {
+			return nil, fmt.Errorf("page_order berisi indeks ganda: %d", index)
+		}
+		seen[index] = true
+		order = append(order, index)
+	}
+
+	if len(order) != count {
+		return nil, fmt.Errorf("page_order harus memuat semua %d file", count)
+	}
+
+	return order, nil
+}
+
+// pdfFileName menentukan nama file hasil penggabungan.
+func pdfFileName(fileName, subject string) string {
+	name := sanitizeArchiveName(fileName)
+	if name == "" {
+		name = sanitizeArchiveName(subject)
+	}
+	if name == "" {
+		name = "dokumen"
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+
+	return name
+}
+
+// createCombinedPDF adalah mode upload mode=pdf: semua part "files" harus
+// gambar dan digabung menjadi satu dokumen PDF sesuai page_order. Aturan
+// jenis dokumen diterapkan pada PDF hasil gabungan.
+func createCombinedPDF(c *gin.Context, employeeID string, form *uploadForm, docType *document_type.DocumentType) {
+	subject := documentSubject(form.fields.Get("subject"), docType)
+	if subject == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject atau jenis dokumen wajib diisi"})
+		return
+	}
+
+	fileName := pdfFileName(form.fields.Get("file_name"), subject)
+	if err := checkDocumentType(docType, fileName, employeeID, ""); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	order, err := pageOrder(form.fields.Get("page_order"), len(form.spooled))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var pages []*os.File
+	defer func() { removeTempFiles(pages) }()
+
+	for _, index := range order {
+		spooled := form.spooled[index]
+		if spooled.TooLarge {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Ukuran file %s melebihi batas", spooled.FileName)})
+			return
+		}
+
+		page, err := spooledImagePage(spooled)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Halaman %s: %v", spooled.FileName, err)})
+			return
+		}
+		pages = append(pages, page)
+	}
+
+	merged, err := mergePDFs(pages)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer removeTempFiles([]*os.File{merged})
+
+	if info, err := merged.Stat(); err == nil {
+		if err := checkDocumentTypeSize(docType, info.Size()); err != nil {
+			respondUploadError(c, err)
+			return
+		}
+
+		if !checkQuota(c, employeeID, info.Size(), 1) {
+			return
+		}
+	}
+
+	file, err := saveFile(merged, fileName, employeeID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if uploadErr, ok := err.(*uploadError); ok {
+			status = uploadErr.status
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	combined := &uploadForm{file: file}
+	documentID := uuid.NewString()
+	document := DocumentStaff{
+		ID:               documentID,
+		EmployeeID:       employeeID,
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          subject,
+		FileName:         file.FileName,
+		FileURL:          fileURL(documentID, file.Object),
+		PublicID:         file.Object.PublicID,
+		ResourceType:     file.ResourceType,
+		MimeType:         file.MimeType,
+		Checksum:         file.Checksum,
+		Size:             file.Size,
+		OriginalSize:     file.OriginalSize,
+		OriginalChecksum: file.OriginalChecksum,
+		UploadedBy:       employeeID,
+	}
+
+	if err := createDocument(&document, docType); err != nil {
+		combined.discard()
+		if _, ok := err.(*uploadError); ok {
+			respondUploadError(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan dokumen: " + err.Error()})
+		return
+	}
+
+	queueFileProcessing(document.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  fmt.Sprintf("%d halaman berhasil digabung menjadi satu PDF", len(pages)),
+		"document": withSignedURL(document),
+	})
+}
+
+// spooledImagePage memeriksa isi file sementara (harus gambar) lalu
+// mengubahnya menjadi satu halaman PDF.
+func spooledImagePage(spooled *spooledFile) (*os.File, error) {
+	f, err := os.Open(spooled.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inspector, err := filecheck.NewInspector(f, spooled.FileName)
+	if err != nil {
+		return nil, errors.New(inspectionMessage(err))
+	}
+
+	if !strings.HasPrefix(inspector.MimeType(), "image/") {
+		return nil, fmt.Errorf("file harus berupa gambar")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(inspector, maxUploadSize("image")+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxUploadSize("image") {
+		return nil, fmt.Errorf("ukuran gambar melebihi batas %d MB", maxUploadSize("image")>>20)
+	}
+
+	if err := inspector.Verdict(); err != nil {
+		return nil, errors.New(inspectionMessage(err))
+	}
+
+	return imagePagePDF(data, inspector.MimeType(), spooled.FileName)
+}
+
+type MergeDocumentsRequest struct {
+	DocumentIDs    []string `json:"document_ids" form:"document_ids"`
+	DocumentTypeID string   `json:"document_type_id" form:"document_type_id"`
+	Subject        string   `json:"subject" form:"subject"`
+	FileName       string   `json:"file_name" form:"file_name"`
+	TrashSources   bool     `json:"trash_sources" form:"trash_sources"`
+}
+
+// ======================================================
+// MERGE DOCUMENTS INTO ONE PDF - ADMIN ONLY
+// ======================================================
+// Dokumen PDF dan gambar milik satu pegawai digabung sesuai urutan
+// document_ids menjadi dokumen baru. Dokumen sumber dipindahkan ke trash jika
+// trash_sources bernilai true. Jenis dokumen sumber tidak diwariskan; hasil
+// gabungan hanya berjenis jika document_type_id diisi, dan aturan jenis
+// tersebut (ekstensi, ukuran, satu per pegawai) diterapkan pada PDF hasil
+// gabungan. Kuota penyimpanan pegawai diperiksa sebelum file disimpan.
+func MergeDocumentsStaffAdmin(c *gin.Context) {
+	var req MergeDocumentsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	docType, err := documentTypeFromFields(url.Values{"document_type_id": {req.DocumentTypeID}})
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	subject := documentSubject(req.Subject, docType)
+	if subject == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject atau jenis dokumen wajib diisi"})
+		return
+	}
+
+	if len(req.DocumentIDs) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Minimal dua dokumen untuk digabung"})
+		return
+	}
+
+	var documents []DocumentStaff
+	if err := database.DB.Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data dokumen: " + err.Error()})
+		return
+	}
+
+	byID := make(map[string]DocumentStaff, len(documents))
+	for _, document := range documents {
+		byID[document.ID] = document
+	}
+
+	ordered := make([]DocumentStaff, 0, len(req.DocumentIDs))
+	seen := map[string]bool{}
+	for _, id := range req.DocumentIDs {
+		document, ok := byID[id]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dokumen tidak ditemukan: " + id})
+			return
+		}
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dokumen ganda: " + id})
+			return
+		}
+		seen[id] = true
+		ordered = append(ordered, document)
+	}
+
+	employeeID := ordered[0].EmployeeID
+	for _, document := range ordered {
+		if document.EmployeeID == "" || document.EmployeeID != employeeID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Semua dokumen harus milik pegawai yang sama"})
+			return
+		}
+	}
+
+	// Sumber yang ikut dipindahkan ke trash tidak dihitung sebagai dokumen
+	// lain berjenis sama.
+	excludeID := ""
+	if docType != nil && req.TrashSources {
+		for _, document := range ordered {
+			if document.DocumentTypeID == docType.ID {
+				excludeID = document.ID
+			}
+		}
+	}
+
+	fileName := pdfFileName(req.FileName, subject)
+	if err := checkDocumentType(docType, fileName, employeeID, excludeID); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	var parts []*os.File
+	defer func() { removeTempFiles(parts) }()
+
+	for _, document := range ordered {
+		part, err := documentAsPDF(document)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Dokumen %s tidak dapat digabung: %v", document.FileName, err)})
+			return
+		}
+		parts = append(parts, part)
+	}
+
+	merged, err := mergePDFs(parts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer removeTempFiles([]*os.File{merged})
+
+	info, err := merged.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca PDF hasil gabungan: " + err.Error()})
+		return
+	}
+
+	if err := checkDocumentTypeSize(docType, info.Size()); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	if !checkQuota(c, employeeID, info.Size(), 1) {
+		return
+	}
+
+	file, err := saveFile(merged, fileName, employeeID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if uploadErr, ok := err.(*uploadError); ok {
+			status = uploadErr.status
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	form := &uploadForm{file: file}
+	documentID := uuid.NewString()
+	document := DocumentStaff{
+		ID:               documentID,
+		EmployeeID:       employeeID,
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          subject,
+		FileName:         file.FileName,
+		FileURL:          fileURL(documentID, file.Object),
+		PublicID:         file.Object.PublicID,
+		ResourceType:     file.ResourceType,
+		MimeType:         file.MimeType,
+		Checksum:         file.Checksum,
+		Size:             file.Size,
+		OriginalSize:     file.OriginalSize,
+		OriginalChecksum: file.OriginalChecksum,
+		UploadedBy:       c.GetString("employeeID"),
+	}
+
+	tx := database.DB.Begin()
+
+	if err := claimDocumentType(tx, docType, employeeID, excludeID); err != nil {
+		tx.Rollback()
+		form.discard()
+		respondUploadError(c, err)
+		return
+	}
+
+	if err := tx.Create(&document).Error; err != nil {
+		tx.Rollback()
+		form.discard()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan dokumen: " + err.Error()})
+		return
+	}
+
+	if req.TrashSources {
+		if err := tx.Where("id IN ?", req.DocumentIDs).Delete(&DocumentStaff{}).Error; err != nil {
+			tx.Rollback()
+			form.discard()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memindahkan dokumen sumber ke trash: " + err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		form.discard()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan dokumen: " + err.Error()})
+		return
+	}
+
+	queueFileProcessing(document.ID)
+	if req.TrashSources {
+		for _, sourceID := range req.DocumentIDs {
+			syncSearchIndex(sourceID)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  fmt.Sprintf("%d dokumen berhasil digabung menjadi satu PDF", len(ordered)),
+		"document": withSignedURL(document),
+	})
+}
+
+// documentAsPDF menyalin file dokumen ke file sementara. PDF dipakai apa
+// adanya, gambar diubah menjadi satu halaman PDF.
+func documentAsPDF(document DocumentStaff) (*os.File, error) {
+	isPDF := document.MimeType == "application/pdf"
+	if !isPDF && !strings.HasPrefix(document.MimeType, "image/") {
+		return nil, fmt.Errorf("hanya PDF dan gambar yang dapat digabung")
+	}
+
+	reader, err := store.Open(document.PublicID, document.ResourceType)
+	if err != nil {
+		return nil, fmt.Errorf("file tidak dapat dibuka: %v", err)
+	}
+	defer reader.Close()
+
+	if !isPDF {
+		limit := maxUploadSize("image")
+		data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("ukuran file melebihi batas %d MB", limit>>20)
+		}
+		return imagePagePDF(data, document.MimeType, document.FileName)
+	}
+
+	part, err := tempPDF()
+	if err != nil {
+		return nil, err
+	}
+
+	// Baca satu byte melebihi batas agar file yang terlalu besar ditolak,
+	// bukan terpotong diam-diam menjadi PDF rusak.
+	limit := maxUploadSize("raw")
+	n, err := io.Copy(part, io.LimitReader(reader, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("ukuran file melebihi batas %d MB", limit>>20)
+	}
+	if err != nil {
+		removeTempFiles([]*os.File{part})
+		return nil, err
+	}
+
+	return part, nil
+}
@@ -0,0 +1,67 @@
+package document_staff
+
+import (
+	"net/http"
+
+	"BackendKantorDinsos/domain/storage_quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkQuota memastikan upload pegawai masih muat di kuotanya. Jika tidak,
+// response 413 (atau 500 saat kuota gagal dibaca) sudah dikirim.
+func checkQuota(c *gin.Context, employeeID string, incomingBytes, newDocuments int64) bool {
+	if err := storage_quota.Check(employeeID, incomingBytes, newDocuments); err != nil {
+		if storage_quota.IsExceeded(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return false
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa kuota penyimpanan: " + err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// precheckQuota menolak upload multipart sebelum body dibaca jika ukuran
+// request saja sudah melewati sisa kuota. Field form selain file dianggap
+// paling besar maxFormValueSize.
+func precheckQuota(c *gin.Context, employeeID string, newDocuments int64) bool {
+	return checkQuota(c, employeeID, requestFileSize(c), newDocuments)
+}
+
+// precheckQuotaError sama dengan precheckQuota tetapi mengembalikan error
+// untuk dipakai di prepareUpload. Dengan begitu pemeriksaan hanya berlaku
+// jika part file benar-benar dikirim; update tanpa file tidak ditolak
+// walaupun pemakaian pegawai sudah melewati kuota yang diturunkan admin.
+func precheckQuotaError(c *gin.Context, employeeID string, newDocuments int64) error {
+	if err := storage_quota.Check(employeeID, requestFileSize(c), newDocuments); err != nil {
+		if storage_quota.IsExceeded(err) {
+			return &uploadError{http.StatusRequestEntityTooLarge, err.Error()}
+		}
+		return &uploadError{http.StatusInternalServerError, "Gagal memeriksa kuota penyimpanan: " + err.Error()}
+	}
+
+	return nil
+}
+
+// requestFileSize memperkirakan ukuran file dari Content-Length request.
+func requestFileSize(c *gin.Context) int64 {
+	incoming := c.Request.ContentLength - maxFormValueSize
+	if incoming < 0 {
+		incoming = 0
+	}
+
+	return incoming
+}
+
+// tusQuotaError memeriksa kuota upload tus yang sudah lengkap.
+func tusQuotaError(upload TusUpload) error {
+	err := storage_quota.Check(upload.EmployeeID, upload.Length, 1)
+	if err != nil && storage_quota.IsExceeded(err) {
+		return &uploadError{http.StatusRequestEntityTooLarge, err.Error()}
+	}
+
+	return err
+}
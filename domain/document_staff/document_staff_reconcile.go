@@ -0,0 +1,242 @@
+package document_staff
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/storage"
+)
+
+// folder storage yang dikelola dokumen staff beserta resource type-nya
+var managedFolders = []struct {
+	Folder       string
+	ResourceType string
+}{
+	{"document_staff", "raw"},
+	{"gambar", "image"},
+	{thumbnailFolder, "image"},
+	{originalFolder, "image"},
+}
+
+type ReconcileItem struct {
+	PublicID     string `json:"public_id"`
+	ResourceType string `json:"resource_type"`
+	DocumentID   string `json:"document_id,omitempty"`
+	VersionID    string `json:"version_id,omitempty"`
+	Repaired     bool   `json:"repaired"`
+	Error        string `json:"error,omitempty"`
+}
+
+// reconcileGracePeriod adalah umur minimal file di storage sebelum boleh
+// dianggap orphan. File yang lebih muda bisa jadi sudah selesai di-Put tetapi
+// barisnya belum di-commit (finalisasi tus, worker batch, merge).
+const reconcileGracePeriod = time.Hour
+
+// ReconcileReport berisi dua jenis selisih: file di storage yang tidak
+// dirujuk database (orphan) dan baris database yang filenya sudah tidak ada.
+// RecentObjects adalah file tanpa rujukan yang dilewati karena umurnya belum
+// melewati reconcileGracePeriod.
+type ReconcileReport struct {
+	StartedAt     time.Time       `json:"started_at"`
+	FinishedAt    time.Time       `json:"finished_at"`
+	Repair        bool            `json:"repair"`
+	RemoteObjects int             `json:"remote_objects"`
+	RecentObjects int             `json:"recent_objects"`
+	OrphanObjects []ReconcileItem `json:"orphan_objects"`
+	MissingFiles  []ReconcileItem `json:"missing_files"`
+}
+
+// Reconcile membandingkan isi folder storage dengan kolom public_id di
+// database. Jika repair bernilai true, file orphan dijadwalkan untuk dihapus
+// lewat outbox, dokumen yang filenya hilang dipindahkan ke trash, dan versi
+// yang filenya hilang dihapus dari riwayat.
+func Reconcile(repair bool) (ReconcileReport, error) {
+	report := ReconcileReport{
+		StartedAt:     time.Now(),
+		Repair:        repair,
+		OrphanObjects: []ReconcileItem{},
+		MissingFiles:  []ReconcileItem{},
+	}
+
+	lister, ok := store.(storage.Lister)
+	if !ok {
+		return report, fmt.Errorf("storage yang dipakai tidak mendukung listing file")
+	}
+
+	// Listing diambil sebelum membaca database, sehingga file yang sudah
+	// tercatat saat listing pasti ikut terbaca sebagai rujukan. File yang
+	// tersimpan tetapi barisnya belum di-commit tidak terlindungi oleh urutan
+	// ini, jadi file yang lebih muda dari reconcileGracePeriod dilewati.
+	listed := map[string][]storage.ListedObject{}
+	for _, folder := range managedFolders {
+		objects, err := lister.List(folder.Folder, folder.ResourceType)
+		if err != nil {
+			return report, fmt.Errorf("gagal mengambil daftar file %s: %v", folder.Folder, err)
+		}
+		listed[folder.Folder] = objects
+	}
+
+	cutoff := report.StartedAt.Add(-reconcileGracePeriod)
+
+	referenced, err := referencedPublicIDs()
+	if err != nil {
+		return report, err
+	}
+
+	remote := map[string]bool{}
+	for _, folder := range managedFolders {
+		for _, object := range listed[folder.Folder] {
+			publicID := object.PublicID
+			remote[publicID] = true
+			report.RemoteObjects++
+
+			if referenced[publicID] {
+				continue
+			}
+
+			if object.CreatedAt.After(cutoff) {
+				report.RecentObjects++
+				continue
+			}
+
+			item := ReconcileItem{PublicID: publicID, ResourceType: folder.ResourceType}
+			if repair {
+				if err := enqueueStorageDeletion(database.DB, publicID, folder.ResourceType); err != nil {
+					item.Error = err.Error()
+				} else {
+					item.Repaired = true
+				}
+			}
+			report.OrphanObjects = append(report.OrphanObjects, item)
+		}
+	}
+
+	var documents []DocumentStaff
+	if err := database.DB.Where("public_id <> ''").Find(&documents).Error; err != nil {
+		return report, err
+	}
+
+	for _, document := range documents {
+		if remote[document.PublicID] || fileExists(document.PublicID, document.ResourceType) {
+			continue
+		}
+
+		item := ReconcileItem{PublicID: document.PublicID, ResourceType: document.ResourceType, DocumentID: document.ID}
+		if repair {
+			if err := database.DB.Delete(&document).Error; err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Repaired = true
+				syncSearchIndex(document.ID)
+			}
+		}
+		report.MissingFiles = append(report.MissingFiles, item)
+	}
+
+	var versions []DocumentVersion
+	if err := database.DB.Where("public_id <> ''").Find(&versions).Error; err != nil {
+		return report, err
+	}
+
+	for _, version := range versions {
+		if remote[version.PublicID] || fileExists(version.PublicID, version.ResourceType) {
+			continue
+		}
+
+		item := ReconcileItem{PublicID: version.PublicID, ResourceType: version.ResourceType, DocumentID: version.DocumentID, VersionID: version.ID}
+		if repair {
+			if err := database.DB.Delete(&version).Error; err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Repaired = true
+			}
+		}
+		report.MissingFiles = append(report.MissingFiles, item)
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// referencedPublicIDs mengumpulkan semua public id yang masih dirujuk
+// database, termasuk dokumen di trash dan versi lama.
+func referencedPublicIDs() (map[string]bool, error) {
+	referenced := map[string]bool{}
+
+	var documentIDs []string
+	if err := database.DB.Unscoped().Model(&DocumentStaff{}).
+		Where("public_id <> ''").
+		Pluck("public_id", &documentIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var versionIDs []string
+	if err := database.DB.Model(&DocumentVersion{}).
+		Where("public_id <> ''").
+		Pluck("public_id", &versionIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var thumbnailIDs []string
+	if err := database.DB.Unscoped().Model(&DocumentStaff{}).
+		Where("thumbnail_public_id <> ''").
+		Pluck("thumbnail_public_id", &thumbnailIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var originalIDs []string
+	if err := database.DB.Unscoped().Model(&DocumentStaff{}).
+		Where("original_public_id <> ''").
+		Pluck("original_public_id", &originalIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var versionOriginalIDs []string
+	if err := database.DB.Model(&DocumentVersion{}).
+		Where("original_public_id <> ''").
+		Pluck("original_public_id", &versionOriginalIDs).Error; err != nil {
+		return nil, err
+	}
+
+	for _, ids := range [][]string{documentIDs, versionIDs, thumbnailIDs, originalIDs, versionOriginalIDs} {
+		for _, id := range ids {
+			referenced[id] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// fileExists memastikan ulang keberadaan file satu per satu, karena hasil
+// listing bisa tertinggal dari upload yang baru saja selesai.
+func fileExists(publicID, resourceType string) bool {
+	exists, err := store.Exists(publicID, resourceType)
+	if err != nil {
+		// Anggap ada jika storage tidak bisa dicek agar tidak salah perbaiki.
+		log.Printf("⚠️ Gagal mengecek file %s: %v", publicID, err)
+		return true
+	}
+	return exists
+}
+
+// StartReconcileScheduler menjalankan Reconcile secara berkala dan mencatat
+// hasilnya ke log.
+func StartReconcileScheduler(interval time.Duration, repair bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := Reconcile(repair)
+			if err != nil {
+				log.Printf("⚠️ Rekonsiliasi storage gagal: %v", err)
+				continue
+			}
+
+			log.Printf("🔎 Rekonsiliasi storage: %d file, %d orphan, %d file hilang (repair=%v)",
+				report.RemoteObjects, len(report.OrphanObjects), len(report.MissingFiles), repair)
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+package document_staff
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/clamav"
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	virusScanner *clamav.Client
+	scanMode     = clamav.ModeOff
+)
+
+// SetVirusScanner memasang client clamd beserta mode pemindaian.
+func SetVirusScanner(client *clamav.Client, mode clamav.Mode) {
+	virusScanner = client
+	scanMode = mode
+}
+
+// SecurityEvent mencatat kejadian keamanan terkait upload, misalnya file
+// yang terdeteksi mengandung virus.
+type SecurityEvent struct {
+	ID        string    `gorm:"type:char(36);primaryKey" json:"id"`
+	Type      string    `gorm:"type:varchar(50);index" json:"type"`
+	OwnerID   string    `gorm:"type:char(36);index" json:"owner_id"`
+	FileName  string    `gorm:"type:varchar(500)" json:"file_name"`
+	Detail    string    `gorm:"type:text" json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e *SecurityEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	return
+}
+
+func recordSecurityEvent(eventType, owner, fileName, detail string) {
+	log.Printf("🚨 SECURITY %s: owner=%s file=%q %s", eventType, owner, fileName, detail)
+
+	if err := database.DB.Create(&SecurityEvent{
+		Type:     eventType,
+		OwnerID:  owner,
+		FileName: fileName,
+		Detail:   detail,
+	}).Error; err != nil {
+		log.Printf("⚠️ Gagal menyimpan security event: %v", err)
+	}
+}
+
+// startScan membuka sesi INSTREAM ke clamd. Mengembalikan nil jika
+// pemindaian mati, atau clamd tidak bisa dihubungi dalam mode fail-open.
+func startScan(fileName string) (*clamav.Stream, error) {
+	if virusScanner == nil || scanMode == clamav.ModeOff {
+		return nil, nil
+	}
+
+	stream, err := virusScanner.NewStream()
+	if err == nil {
+		return stream, nil
+	}
+
+	if scanMode == clamav.ModeFailOpen {
+		log.Printf("⚠️ Antivirus tidak tersedia, %q diterima tanpa dipindai: %v", fileName, err)
+		return nil, nil
+	}
+
+	return nil, &uploadError{http.StatusServiceUnavailable, "Layanan antivirus sedang tidak tersedia, silakan coba lagi"}
+}
+
+// finishScan membaca putusan clamd setelah seluruh file terkirim. File yang
+// melebihi StreamMaxLength clamd tidak akan pernah bisa dipindai, jadi dalam
+// mode fail-closed ditolak dengan 413, bukan 503 yang mengajak mencoba lagi.
+func finishScan(stream *clamav.Stream, fileName, owner string) error {
+	if stream == nil {
+		return nil
+	}
+
+	result, err := stream.Result()
+	if err != nil {
+		if scanMode == clamav.ModeFailOpen {
+			log.Printf("⚠️ Pemindaian %q gagal, file diterima: %v", fileName, err)
+			return nil
+		}
+		if errors.Is(err, clamav.ErrSizeLimit) {
+			return &uploadError{http.StatusRequestEntityTooLarge, "Ukuran file melebihi batas pemindaian antivirus"}
+		}
+		return &uploadError{http.StatusServiceUnavailable, "File tidak dapat dipindai antivirus, silakan coba lagi"}
+	}
+
+	if result.Infected {
+		recordSecurityEvent("malware_detected", owner, fileName, result.Signature)
+		return &uploadError{http.StatusUnprocessableEntity, "File terdeteksi mengandung virus (" + result.Signature + ") dan ditolak"}
+	}
+
+	return nil
+}
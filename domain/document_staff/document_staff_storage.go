@@ -0,0 +1,68 @@
+package document_staff
+
+import (
+	"fmt"
+	"log"
+
+	"BackendKantorDinsos/infrastructure/storage"
+)
+
+var store storage.Storage
+
+// SetStorage memasang backend storage yang dipakai handler dokumen staff.
+func SetStorage(s storage.Storage) {
+	store = s
+}
+
+// RotateStorageKeys membungkus ulang data key file dengan master key aktif.
+func RotateStorageKeys() (int, error) {
+	rotator, ok := store.(storage.KeyRotator)
+	if !ok {
+		return 0, fmt.Errorf("enkripsi storage tidak aktif (STORAGE_MASTER_KEYS kosong)")
+	}
+
+	return rotator.RotateKeys()
+}
+
+// fileURL mengembalikan URL file dokumen. Backend tanpa URL publik (misalnya
+// storage lokal) diarahkan ke endpoint download yang terautentikasi.
+func fileURL(documentID string, obj storage.Object) string {
+	if obj.URL != "" {
+		return obj.URL
+	}
+
+	return "/api/document_staff/" + documentID + "/download"
+}
+
+// ownerID menentukan pemilik file untuk penataan folder di storage.
+func ownerID(userID, employeeID string) string {
+	if employeeID != "" {
+		return employeeID
+	}
+
+	return userID
+}
+
+// resolveFileURL mengembalikan URL yang dikirim ke client. Backend yang
+// mendukung URL bertanda tangan selalu memberi URL berumur pendek, bukan
+// kolom file_url yang permanen.
+func resolveFileURL(storedURL, publicID, resourceType string) string {
+	signer, ok := store.(storage.Signer)
+	if !ok || publicID == "" {
+		return storedURL
+	}
+
+	signed, err := signer.SignedURL(publicID, resourceType, storage.URLTTL())
+	if err != nil {
+		log.Printf("⚠️ Gagal membuat URL bertanda tangan untuk %s: %v", publicID, err)
+		return storedURL
+	}
+
+	return signed
+}
+
+// withSignedURL mengganti FileURL dokumen sebelum dikirim sebagai response.
+func withSignedURL(document DocumentStaff) DocumentStaff {
+	document.FileURL = resolveFileURL(document.FileURL, document.PublicID, document.ResourceType)
+	return document
+}
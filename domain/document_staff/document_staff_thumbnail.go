@@ -0,0 +1,322 @@
+package document_staff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/filecheck"
+	"BackendKantorDinsos/infrastructure/storage"
+
+	"github.com/gin-gonic/gin"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	thumbnailFolder   = "thumbnail"
+	thumbnailMaxSide  = 320
+	thumbnailMaxPixel = 50_000_000
+)
+
+// errNoRenderer dikembalikan jika file tidak bisa dibuatkan preview, baik
+// karena tipenya tidak didukung maupun karena pdftoppm/ghostscript tidak
+// terpasang.
+var errNoRenderer = errors.New("renderer preview tidak tersedia")
+
+var thumbnailQueue = make(chan string, 256)
+
+// queueThumbnail menjadwalkan pembuatan thumbnail tanpa menahan request. Jika
+// antrean penuh, dokumen dilewati dan bisa dibuat ulang lewat perintah
+// "thumbnails".
+func queueThumbnail(documentID string) {
+	select {
+	case thumbnailQueue <- documentID:
+	default:
+		log.Printf("⚠️ Antrean thumbnail penuh, dokumen %s dilewati", documentID)
+	}
+}
+
+// StartThumbnailWorker memproses antrean thumbnail di background.
+func StartThumbnailWorker() {
+	go func() {
+		for documentID := range thumbnailQueue {
+			if _, err := generateThumbnail(documentID); err != nil && !errors.Is(err, errNoRenderer) {
+				log.Printf("⚠️ Gagal membuat thumbnail dokumen %s: %v", documentID, err)
+			}
+		}
+	}()
+}
+
+// generateThumbnail membuat thumbnail JPEG untuk file dokumen saat ini dan
+// menyimpannya di folder thumbnail. Mengembalikan true jika thumbnail baru
+// benar-benar terpasang; dokumen tanpa file atau yang filenya diganti selama
+// proses dilewati tanpa error.
+func generateThumbnail(documentID string) (bool, error) {
+	var document DocumentStaff
+	if err := database.DB.First(&document, "id = ?", documentID).Error; err != nil {
+		return false, err
+	}
+
+	if document.PublicID == "" {
+		return false, nil
+	}
+
+	file, err := store.Open(document.PublicID, document.ResourceType)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	img, err := renderThumbnail(file, documentMimeType(document))
+	if err != nil {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return false, err
+	}
+
+	object, err := store.Put(&buf, storage.PutOptions{
+		FileName:     document.ID + ".jpg",
+		Folder:       thumbnailFolder,
+		ResourceType: "image",
+		OwnerID:      ownerID(document.UserID, document.EmployeeID),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Thumbnail hanya dipasang jika file dokumen belum diganti selama proses.
+	tx := database.DB.Begin()
+
+	result := tx.Model(&DocumentStaff{}).
+		Where("id = ? AND public_id = ?", document.ID, document.PublicID).
+		Update("thumbnail_public_id", object.PublicID)
+	if result.Error != nil || result.RowsAffected == 0 {
+		tx.Rollback()
+		scheduleStorageDeletion(object.PublicID, "image")
+		return false, result.Error
+	}
+
+	if err := enqueueStorageDeletion(tx, document.ThumbnailPublicID, "image"); err != nil {
+		tx.Rollback()
+		scheduleStorageDeletion(object.PublicID, "image")
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		scheduleStorageDeletion(object.PublicID, "image")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// documentMimeType mengembalikan MIME type dokumen. Dokumen lama yang dibuat
+// sebelum kolom mime_type ada tidak memiliki nilai, sehingga jenisnya
+// ditebak dari ekstensi nama file (atau public ID untuk file raw Cloudinary).
+func documentMimeType(document DocumentStaff) string {
+	if document.MimeType != "" {
+		return document.MimeType
+	}
+
+	for _, name := range []string{document.FileName, document.PublicID} {
+		if mimeType, ok := filecheck.MimeTypeByExtension(name); ok {
+			return mimeType
+		}
+	}
+
+	return ""
+}
+
+// renderThumbnail mengubah isi file menjadi gambar kecil. Gambar didecode
+// langsung, PDF dirender halaman pertamanya lewat pdftoppm atau ghostscript.
+func renderThumbnail(r io.Reader, mimeType string) (image.Image, error) {
+	var (
+		img image.Image
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		img, err = decodeImage(r, thumbnailMaxPixel)
+	case mimeType == "application/pdf":
+		img, err = renderPDFFirstPage(r)
+	default:
+		return nil, errNoRenderer
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resizeToFit(img, thumbnailMaxSide, true), nil
+}
+
+// errImageTooLarge dikembalikan decodeImage untuk gambar yang jumlah
+// pikselnya melewati batas.
+var errImageTooLarge = errors.New("gambar terlalu besar")
+
+// decodeImage menolak gambar dengan jumlah piksel di atas maxPixels sebelum
+// didecode agar tidak menghabiskan memori.
+func decodeImage(r io.Reader, maxPixels int) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w (%dx%d)", errImageTooLarge, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// renderPDFFirstPage menyalin PDF ke file sementara lalu merender halaman
+// pertama menjadi PNG. pdftoppm (poppler) dipakai lebih dulu, ghostscript
+// sebagai cadangan.
+func renderPDFFirstPage(r io.Reader) (image.Image, error) {
+	dir, err := os.MkdirTemp("", "thumbnail-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.pdf")
+	f, err := os.Create(input)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	output := filepath.Join(dir, "page.png")
+
+	var cmd *exec.Cmd
+	if path, err := exec.LookPath("pdftoppm"); err == nil {
+		cmd = exec.CommandContext(ctx, path, "-f", "1", "-l", "1", "-singlefile",
+			"-scale-to", fmt.Sprint(thumbnailMaxSide*2), "-png", input, strings.TrimSuffix(output, ".png"))
+	} else if path, err := exec.LookPath("gs"); err == nil {
+		cmd = exec.CommandContext(ctx, path, "-q", "-dSAFER", "-dBATCH", "-dNOPAUSE",
+			"-sDEVICE=png16m", "-dFirstPage=1", "-dLastPage=1", "-r72",
+			"-sOutputFile="+output, input)
+	} else {
+		return nil, errNoRenderer
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("render PDF gagal: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	page, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer page.Close()
+
+	return decodeImage(page, thumbnailMaxPixel)
+}
+
+// resetThumbnail menjadwalkan penghapusan thumbnail lama ketika file dokumen
+// diganti. Thumbnail baru dibuat setelah transaksi selesai.
+func resetThumbnail(tx *gorm.DB, document *DocumentStaff) error {
+	if err := enqueueStorageDeletion(tx, document.ThumbnailPublicID, "image"); err != nil {
+		return err
+	}
+
+	document.ThumbnailPublicID = ""
+	return nil
+}
+
+// thumbnailURL mengembalikan URL thumbnail untuk response listing. Backend
+// tanpa URL bertanda tangan diarahkan ke endpoint thumbnail.
+func thumbnailURL(documentID, thumbnailPublicID string) string {
+	if thumbnailPublicID == "" {
+		return ""
+	}
+
+	if _, ok := store.(storage.Signer); ok {
+		return resolveFileURL("", thumbnailPublicID, "image")
+	}
+
+	return "/api/document_staff/" + documentID + "/thumbnail"
+}
+
+// ======================================================
+// GET DOCUMENT THUMBNAIL - FOR ALL ROLES
+// ======================================================
+func GetDocumentThumbnail(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if document.ThumbnailPublicID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail belum tersedia"})
+		return
+	}
+
+	serveStoredFile(c, storedFile{
+		PublicID:     document.ThumbnailPublicID,
+		ResourceType: "image",
+		FileName:     document.ID + ".jpg",
+		MimeType:     "image/jpeg",
+		ModTime:      document.UpdatedAt,
+		Inline:       true,
+	})
+}
+
+// BackfillThumbnails membuat thumbnail untuk dokumen yang belum memilikinya.
+// Mengembalikan jumlah thumbnail yang berhasil dibuat.
+func BackfillThumbnails() (int, error) {
+	var documentIDs []string
+	if err := database.DB.Model(&DocumentStaff{}).
+		Where("public_id <> '' AND (thumbnail_public_id IS NULL OR thumbnail_public_id = '')").
+		Pluck("id", &documentIDs).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, documentID := range documentIDs {
+		stored, err := generateThumbnail(documentID)
+		if err != nil {
+			if !errors.Is(err, errNoRenderer) {
+				log.Printf("⚠️ Gagal membuat thumbnail dokumen %s: %v", documentID, err)
+			}
+			continue
+		}
+		if stored {
+			created++
+		}
+	}
+
+	return created, nil
+}
@@ -0,0 +1,248 @@
+package document_staff
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashRetention adalah lama dokumen disimpan di trash sebelum dihapus
+// permanen, diatur lewat env TRASH_RETENTION_DAYS. Default 30 hari.
+func trashRetention() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+// ======================================================
+// GET TRASH - OWNER SEES OWN DOCUMENTS, ADMIN SEES ALL
+// ======================================================
+func GetTrashDocumentsStaff(c *gin.Context) {
+	role, employeeID := callerIdentity(c)
+	if !isAdminRole(role) && employeeID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - employeeID not found"})
+		return
+	}
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		pageInt = 1
+	}
+
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 {
+		limitInt = 20
+	}
+
+	if limitInt > 100 {
+		limitInt = 100
+	}
+
+	offset := (pageInt - 1) * limitInt
+
+	query := database.DB.Unscoped().Model(&DocumentStaff{}).
+		Where("deleted_at IS NOT NULL")
+
+	if !isAdminRole(role) {
+		query = query.Where("employee_id = ?", employeeID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var documents []DocumentStaff
+	if err := query.
+		Order("deleted_at DESC").
+		Limit(limitInt).
+		Offset(offset).
+		Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data trash: " + err.Error()})
+		return
+	}
+
+	retention := trashRetention()
+	formattedDocuments := make([]map[string]interface{}, len(documents))
+	for i, doc := range documents {
+		formattedDocuments[i] = map[string]interface{}{
+			"id":            doc.ID,
+			"user_id":       doc.UserID,
+			"employee_id":   doc.EmployeeID,
+			"subject":       doc.Subject,
+			"file_name":     doc.FileName,
+			"resource_type": doc.ResourceType,
+			"mime_type":     doc.MimeType,
+			"created_at":    doc.CreatedAt,
+			"deleted_at":    doc.DeletedAt.Time,
+			"purge_at":      doc.DeletedAt.Time.Add(retention),
+		}
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limitInt)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil data trash",
+		"data": gin.H{
+			"documents": formattedDocuments,
+			"pagination": gin.H{
+				"current_page": pageInt,
+				"per_page":     limitInt,
+				"total_items":  total,
+				"total_pages":  totalPages,
+			},
+		},
+	})
+}
+
+// ======================================================
+// RESTORE FROM TRASH - OWNER OR ADMIN
+// ======================================================
+// Dokumen berjenis satu per pegawai tidak bisa dipulihkan jika pegawai sudah
+// memiliki dokumen lain berjenis sama (409).
+func RestoreDocumentStaff(c *gin.Context) {
+	document, ok := findDocumentForCaller(c, database.DB.Unscoped().Where("deleted_at IS NOT NULL"), c.Param("id"))
+	if !ok {
+		return
+	}
+
+	docType, err := effectiveDocumentType(nil, document.DocumentTypeID)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimDocumentType(tx, docType, document.EmployeeID, document.ID); err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Model(&document).Update("deleted_at", nil).Error
+	})
+	if _, ok := err.(*uploadError); ok {
+		respondUploadError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan dokumen: " + err.Error()})
+		return
+	}
+
+	document.DeletedAt.Valid = false
+	syncSearchIndex(document.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Dokumen berhasil dipulihkan dari trash",
+		"document": withSignedURL(document),
+	})
+}
+
+// purgeDocument menghapus permanen baris dokumen beserta seluruh versinya.
+// File di storage (termasuk thumbnail dan file asli gambar) dicatat ke
+// outbox dalam transaksi yang sama dan dihapus oleh worker penghapusan.
+func purgeDocument(document DocumentStaff) error {
+	var versions []DocumentVersion
+	if err := database.DB.Where("document_id = ?", document.ID).Find(&versions).Error; err != nil {
+		return err
+	}
+
+	tx := database.DB.Begin()
+
+	if err := enqueueStorageDeletion(tx, document.PublicID, document.ResourceType); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("gagal menjadwalkan penghapusan file %s: %v", document.PublicID, err)
+	}
+
+	if err := enqueueStorageDeletion(tx, document.ThumbnailPublicID, "image"); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("gagal menjadwalkan penghapusan thumbnail %s: %v", document.ThumbnailPublicID, err)
+	}
+
+	if err := enqueueStorageDeletion(tx, document.OriginalPublicID, "image"); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("gagal menjadwalkan penghapusan file asli %s: %v", document.OriginalPublicID, err)
+	}
+
+	for _, version := range versions {
+		if err := enqueueStorageDeletion(tx, version.PublicID, version.ResourceType); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menjadwalkan penghapusan file %s: %v", version.PublicID, err)
+		}
+
+		if err := enqueueStorageDeletion(tx, version.OriginalPublicID, "image"); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("gagal menjadwalkan penghapusan file asli %s: %v", version.OriginalPublicID, err)
+		}
+	}
+
+	if err := tx.Where("document_id = ?", document.ID).Delete(&DocumentVersion{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("document_id = ?", document.ID).Delete(&DocumentText{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(&document).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	syncSearchIndex(document.ID)
+	return nil
+}
+
+// PurgeTrash menghapus permanen dokumen yang sudah melewati masa retensi.
+func PurgeTrash() (int, error) {
+	var documents []DocumentStaff
+	if err := database.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-trashRetention())).
+		Find(&documents).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, document := range documents {
+		if err := purgeDocument(document); err != nil {
+			log.Printf("⚠️ Gagal purge dokumen %s: %v", document.ID, err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartTrashPurger menjalankan PurgeTrash secara berkala.
+func StartTrashPurger(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := PurgeTrash()
+			if err != nil {
+				log.Printf("⚠️ Gagal menjalankan purge trash: %v", err)
+				continue
+			}
+
+			if purged > 0 {
+				log.Printf("🧹 %d dokumen di trash dihapus permanen", purged)
+			}
+		}
+	}()
+}
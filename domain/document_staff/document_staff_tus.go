@@ -0,0 +1,544 @@
+package document_staff
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/infrastructure/database"
+	"BackendKantorDinsos/infrastructure/filecheck"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const tusVersion = "1.0.0"
+
+// TusUpload menyimpan status upload resumable (protokol tus 1.0). Data yang
+// sudah diterima ditulis ke file sementara di TUS_UPLOAD_DIR dan baru
+// dipindahkan ke storage ketika upload selesai.
+type TusUpload struct {
+	ID             string    `gorm:"type:char(36);primaryKey" json:"id"`
+	EmployeeID     string    `gorm:"type:char(36);index" json:"employee_id"`
+	DocumentTypeID string    `gorm:"type:char(36)" json:"document_type_id"`
+	FileName       string    `gorm:"type:varchar(500)" json:"file_name"`
+	Subject        string    `gorm:"type:varchar(255)" json:"subject"`
+	Length         int64     `json:"length"`
+	Offset         int64     `json:"offset"`
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (t *TusUpload) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	return
+}
+
+// satu upload hanya boleh diproses oleh satu PATCH dalam satu waktu. Entri
+// dihapus oleh removeTusUpload (selesai, dibatalkan atau kedaluwarsa).
+var tusLocks sync.Map
+
+func tusLock(uploadID string) func() {
+	mu, _ := tusLocks.LoadOrStore(uploadID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// lockTusUpload seperti findTusUpload, tetapi sekaligus mengunci upload.
+// Baris dicari dulu sebelum mutex dibuat agar ID sembarang di URL tidak
+// menambah isi tusLocks, lalu dibaca ulang setelah terkunci karena request
+// lain mungkin sudah mengubah atau menghapusnya.
+func lockTusUpload(c *gin.Context) (TusUpload, func(), bool) {
+	if _, ok := findTusUpload(c); !ok {
+		return TusUpload{}, nil, false
+	}
+
+	id := c.Param("uploadId")
+	unlock := tusLock(id)
+
+	upload, ok := findTusUpload(c)
+	if !ok {
+		unlock()
+		tusLocks.Delete(id)
+		return upload, nil, false
+	}
+
+	return upload, unlock, true
+}
+
+func tusDir() string {
+	if dir := os.Getenv("TUS_UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "tus_uploads"
+}
+
+// tusExpiry adalah lama upload yang tidak dilanjutkan sebelum dihapus,
+// diatur lewat env TUS_UPLOAD_EXPIRY (contoh: 24h). Default 24 jam.
+func tusExpiry() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("TUS_UPLOAD_EXPIRY")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+func tusPartPath(uploadID string) string {
+	return filepath.Join(tusDir(), uploadID+".part")
+}
+
+func tusLocation(uploadID string) string {
+	return "/api/document_staff/tus/" + uploadID
+}
+
+// ======================================================
+// TUS - OPTIONS (DISCOVERY)
+// ======================================================
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Header("Tus-Max-Size", strconv.FormatInt(maxUploadSize("raw"), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// ======================================================
+// TUS - CREATE UPLOAD
+// ======================================================
+func TusCreateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	employeeID, ok := tusEmployeeID(c)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Header Upload-Length wajib diisi"})
+		return
+	}
+
+	metadata := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+
+	fileName := filepath.Base(metadata["filename"])
+	if fileName == "" || fileName == "." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Metadata filename wajib diisi"})
+		return
+	}
+
+	docType, err := tusDocumentType(metadata["document_type_id"])
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	subject := documentSubject(metadata["subject"], docType)
+	if subject == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subject atau jenis dokumen wajib diisi"})
+		return
+	}
+
+	mimeType, ok := filecheck.MimeTypeByExtension(fileName)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format file tidak didukung"})
+		return
+	}
+
+	resourceType, _ := resolveResourceType(mimeType)
+	if limit := maxUploadSize(resourceType); length > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Ukuran file melebihi batas %d MB", limit>>20)})
+		return
+	}
+
+	if err := checkDocumentType(docType, fileName, employeeID, ""); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	if err := checkDocumentTypeSize(docType, length); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	if !checkQuota(c, employeeID, length, 1) {
+		return
+	}
+
+	if err := os.MkdirAll(tusDir(), 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyiapkan folder upload: " + err.Error()})
+		return
+	}
+
+	upload := TusUpload{
+		EmployeeID:     employeeID,
+		DocumentTypeID: documentTypeID(docType),
+		FileName:       fileName,
+		Subject:        subject,
+		Length:         length,
+		ExpiresAt:      time.Now().Add(tusExpiry()),
+	}
+
+	if err := database.DB.Create(&upload).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat upload: " + err.Error()})
+		return
+	}
+
+	f, err := os.OpenFile(tusPartPath(upload.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		database.DB.Delete(&upload)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat file upload: " + err.Error()})
+		return
+	}
+	f.Close()
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Location", tusLocation(upload.ID))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// ======================================================
+// TUS - HEAD (GET OFFSET)
+// ======================================================
+func TusHeadUpload(c *gin.Context) {
+	upload, ok := findTusUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// ======================================================
+// TUS - PATCH (APPEND DATA)
+// ======================================================
+func TusPatchUpload(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type harus application/offset+octet-stream"})
+		return
+	}
+
+	upload, unlock, ok := lockTusUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Header Upload-Offset wajib diisi"})
+		return
+	}
+
+	if offset != upload.Offset {
+		c.Header("Tus-Resumable", tusVersion)
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset tidak sesuai"})
+		return
+	}
+
+	f, err := os.OpenFile(tusPartPath(upload.ID), os.O_WRONLY, 0o640)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "File upload tidak dapat dibuka: " + err.Error()})
+		return
+	}
+
+	// Buang sisa data dari PATCH sebelumnya yang terputus sebelum offset dicatat.
+	err = f.Truncate(upload.Offset)
+	if err == nil {
+		_, err = f.Seek(upload.Offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "File upload tidak dapat ditulis: " + err.Error()})
+		return
+	}
+
+	// Data yang sudah diterima tetap dicatat walaupun koneksi terputus di
+	// tengah jalan, sehingga client bisa melanjutkan dari offset terakhir.
+	written, copyErr := io.Copy(f, io.LimitReader(c.Request.Body, upload.Length-upload.Offset))
+	closeErr := f.Close()
+	if closeErr != nil && copyErr == nil {
+		copyErr = closeErr
+	}
+
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(tusExpiry())
+	if err := database.DB.Model(&upload).
+		Select("offset", "expires_at").
+		Updates(map[string]interface{}{"offset": upload.Offset, "expires_at": upload.ExpiresAt}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan progres upload: " + err.Error()})
+		return
+	}
+
+	if copyErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Upload terputus: " + copyErr.Error()})
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	if upload.Offset < upload.Length {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	document, err := finalizeTusUpload(upload)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if uploadErr, ok := err.(*uploadError); ok {
+			status = uploadErr.status
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Upload-Document-Id", document.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// ======================================================
+// TUS - DELETE (TERMINATION)
+// ======================================================
+func TusDeleteUpload(c *gin.Context) {
+	upload, unlock, ok := lockTusUpload(c)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	if err := removeTusUpload(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus upload: " + err.Error()})
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusNoContent)
+}
+
+// finalizeTusUpload memeriksa file yang sudah lengkap, menyimpannya ke
+// storage dan baru kemudian membuat baris DocumentStaff.
+func finalizeTusUpload(upload TusUpload) (DocumentStaff, error) {
+	// Kuota dan aturan jenis dokumen diperiksa ulang karena pemakaian dan
+	// jenis dokumen bisa berubah selama upload berlangsung.
+	docType, err := tusDocumentType(upload.DocumentTypeID)
+	if err == nil {
+		err = checkDocumentType(docType, upload.FileName, upload.EmployeeID, "")
+	}
+	if err == nil {
+		err = tusQuotaError(upload)
+	}
+	if err != nil {
+		if _, ok := err.(*uploadError); ok && uploadErrorStatus(err) != http.StatusInternalServerError {
+			removeTusUpload(upload)
+		}
+		return DocumentStaff{}, err
+	}
+
+	f, err := os.Open(tusPartPath(upload.ID))
+	if err != nil {
+		return DocumentStaff{}, fmt.Errorf("file upload tidak dapat dibuka: %v", err)
+	}
+
+	file, err := saveFile(f, upload.FileName, upload.EmployeeID)
+	f.Close()
+	if err != nil {
+		// File yang ditolak (format, ukuran) tidak akan berubah jika diulang.
+		if _, ok := err.(*uploadError); ok {
+			removeTusUpload(upload)
+		}
+		return DocumentStaff{}, err
+	}
+
+	form := &uploadForm{file: file}
+	if err := checkDocumentTypeSize(docType, file.Size); err != nil {
+		form.discard()
+		removeTusUpload(upload)
+		return DocumentStaff{}, err
+	}
+
+	documentID := uuid.NewString()
+	document := DocumentStaff{
+		ID:               documentID,
+		EmployeeID:       upload.EmployeeID,
+		DocumentTypeID:   documentTypeID(docType),
+		Subject:          upload.Subject,
+		FileName:         file.FileName,
+		FileURL:          fileURL(documentID, file.Object),
+		PublicID:         file.Object.PublicID,
+		ResourceType:     file.ResourceType,
+		MimeType:         file.MimeType,
+		Checksum:         file.Checksum,
+		Size:             file.Size,
+		OriginalSize:     file.OriginalSize,
+		OriginalChecksum: file.OriginalChecksum,
+		OriginalPublicID: file.OriginalPublicID,
+		UploadedBy:       upload.EmployeeID,
+	}
+
+	if err := createDocument(&document, docType); err != nil {
+		form.discard()
+		if _, ok := err.(*uploadError); ok {
+			if uploadErrorStatus(err) != http.StatusInternalServerError {
+				removeTusUpload(upload)
+			}
+			return DocumentStaff{}, err
+		}
+		return DocumentStaff{}, fmt.Errorf("gagal menyimpan dokumen: %v", err)
+	}
+
+	queueFileProcessing(document.ID)
+
+	if err := removeTusUpload(upload); err != nil {
+		log.Printf("⚠️ Gagal membersihkan upload tus %s: %v", upload.ID, err)
+	}
+
+	return document, nil
+}
+
+// tusDocumentType mengambil jenis dokumen dari metadata document_type_id.
+// ID kosong berarti upload tanpa jenis dokumen.
+func tusDocumentType(id string) (*document_type.DocumentType, error) {
+	return documentTypeFromFields(url.Values{"document_type_id": {id}})
+}
+
+func removeTusUpload(upload TusUpload) error {
+	defer tusLocks.Delete(upload.ID)
+
+	if err := os.Remove(tusPartPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return database.DB.Delete(&TusUpload{}, "id = ?", upload.ID).Error
+}
+
+// findTusUpload mengambil upload milik pegawai yang sedang login. Jika gagal,
+// response error sudah dikirim.
+func findTusUpload(c *gin.Context) (TusUpload, bool) {
+	var upload TusUpload
+
+	if !checkTusVersion(c) {
+		return upload, false
+	}
+
+	employeeID, ok := tusEmployeeID(c)
+	if !ok {
+		return upload, false
+	}
+
+	if err := database.DB.First(&upload, "id = ? AND employee_id = ?", c.Param("uploadId"), employeeID).Error; err != nil {
+		c.Header("Tus-Resumable", tusVersion)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload tidak ditemukan"})
+		return upload, false
+	}
+
+	if time.Now().After(upload.ExpiresAt) {
+		removeTusUpload(upload)
+		c.Header("Tus-Resumable", tusVersion)
+		c.JSON(http.StatusGone, gin.H{"error": "Upload sudah kedaluwarsa"})
+		return upload, false
+	}
+
+	return upload, true
+}
+
+func checkTusVersion(c *gin.Context) bool {
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Versi tus tidak didukung"})
+		return false
+	}
+	return true
+}
+
+func tusEmployeeID(c *gin.Context) (string, bool) {
+	employeeIDRaw, exists := c.Get("employeeID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized - employeeID not found"})
+		return "", false
+	}
+
+	employeeID, ok := employeeIDRaw.(string)
+	if !ok || employeeID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid employeeID"})
+		return "", false
+	}
+
+	return employeeID, true
+}
+
+// parseTusMetadata membaca header Upload-Metadata: "key base64,key base64".
+func parseTusMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value := ""
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+
+		metadata[fields[0]] = value
+	}
+
+	return metadata
+}
+
+// StartTusCleanup menjalankan pembersihan berkala untuk upload tus yang sudah
+// kedaluwarsa.
+func StartTusCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			var expired []TusUpload
+			if err := database.DB.Where("expires_at < ?", time.Now()).Find(&expired).Error; err != nil {
+				log.Printf("⚠️ Gagal mengambil upload tus kedaluwarsa: %v", err)
+				continue
+			}
+
+			for _, upload := range expired {
+				unlock := tusLock(upload.ID)
+				if err := removeTusUpload(upload); err != nil {
+					log.Printf("⚠️ Gagal menghapus upload tus %s: %v", upload.ID, err)
+				}
+				unlock()
+			}
+
+			if len(expired) > 0 {
+				log.Printf("🧹 %d upload tus kedaluwarsa dihapus", len(expired))
+			}
+		}
+	}()
+}
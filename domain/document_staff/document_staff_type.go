@@ -0,0 +1,258 @@
+package document_staff
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// documentTypeFromFields membaca field document_type_id. Mengembalikan nil
+// jika field tidak diisi.
+func documentTypeFromFields(fields url.Values) (*document_type.DocumentType, error) {
+	id := strings.TrimSpace(fields.Get("document_type_id"))
+	if id == "" {
+		return nil, nil
+	}
+
+	docType, err := document_type.Find(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &uploadError{http.StatusBadRequest, "Jenis dokumen tidak ditemukan"}
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "Gagal mengambil jenis dokumen: " + err.Error()}
+	}
+
+	return &docType, nil
+}
+
+// effectiveDocumentType mengembalikan jenis dari field document_type_id, atau
+// jenis dokumen saat ini (current) jika field tidak diisi.
+func effectiveDocumentType(fields url.Values, current string) (*document_type.DocumentType, error) {
+	docType, err := documentTypeFromFields(fields)
+	if err != nil || docType != nil || current == "" {
+		return docType, err
+	}
+
+	existing, err := document_type.Find(current)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "Gagal mengambil jenis dokumen: " + err.Error()}
+	}
+
+	return &existing, nil
+}
+
+// checkDocumentTypeUpdate memeriksa aturan jenis dokumen setelah form update
+// terbaca. File baru sudah diperiksa ekstensinya saat prepare, jadi tinggal
+// ukurannya. Tanpa file baru, perpindahan jenis diperiksa terhadap file yang
+// sudah ada.
+func checkDocumentTypeUpdate(fields url.Values, file *uploadedFile, document DocumentStaff, employeeID string) (*document_type.DocumentType, error) {
+	docType, err := effectiveDocumentType(fields, document.DocumentTypeID)
+	if err != nil || docType == nil {
+		return docType, err
+	}
+
+	if file != nil {
+		return docType, checkDocumentTypeSize(docType, file.Size)
+	}
+
+	if docType.ID == document.DocumentTypeID {
+		return docType, nil
+	}
+
+	if err := checkDocumentType(docType, document.FileName, employeeID, document.ID); err != nil {
+		return nil, err
+	}
+
+	return docType, checkDocumentTypeSize(docType, document.Size)
+}
+
+// checkDocumentType memeriksa aturan jenis dokumen yang bisa dinilai sebelum
+// file disimpan: ekstensi nama file dan keunikan per pegawai. excludeID
+// adalah dokumen yang sedang diperbarui agar tidak dianggap duplikat dirinya
+// sendiri. fileName kosong berarti ekstensi tidak diperiksa.
+func checkDocumentType(docType *document_type.DocumentType, fileName, employeeID, excludeID string) error {
+	if docType == nil {
+		return nil
+	}
+
+	if fileName != "" && !docType.AllowsFile(fileName) {
+		return &uploadError{http.StatusBadRequest, fmt.Sprintf("Jenis dokumen %s hanya menerima file: %s", docType.Name, strings.Join(docType.AllowedExtensions, ", "))}
+	}
+
+	return checkUniqueDocumentType(database.DB, docType, employeeID, excludeID)
+}
+
+// checkUniqueDocumentType menolak dokumen kedua berjenis sama milik satu
+// pegawai jika jenisnya UniquePerEmployee.
+func checkUniqueDocumentType(db *gorm.DB, docType *document_type.DocumentType, employeeID, excludeID string) error {
+	if docType == nil || !docType.UniquePerEmployee || employeeID == "" {
+		return nil
+	}
+
+	query := db.Model(&DocumentStaff{}).
+		Where("employee_id = ? AND document_type_id = ?", employeeID, docType.ID)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return &uploadError{http.StatusInternalServerError, "Gagal memeriksa dokumen yang ada: " + err.Error()}
+	}
+
+	if count > 0 {
+		return &uploadError{http.StatusConflict, fmt.Sprintf("Pegawai sudah memiliki dokumen %s. Perbarui dokumen yang ada", docType.Name)}
+	}
+
+	return nil
+}
+
+// claimDocumentType mengulang pemeriksaan keunikan di dalam transaksi yang
+// menyimpan dokumen. Pemeriksaan di checkDocumentType terjadi sebelum upload
+// sehingga dua upload bersamaan bisa sama-sama lolos. Baris jenis dokumen
+// dikunci (SELECT ... FOR UPDATE) agar transaksi untuk jenis yang sama, dan
+// penghapusan jenis itu oleh admin, berjalan bergantian; karena itu fungsi
+// ini harus menjadi query pertama di transaksi, sehingga COUNT sesudahnya
+// melihat dokumen yang baru di-commit.
+func claimDocumentType(tx *gorm.DB, docType *document_type.DocumentType, employeeID, excludeID string) error {
+	if docType == nil {
+		return nil
+	}
+
+	var locked document_type.DocumentType
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", docType.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &uploadError{http.StatusBadRequest, "Jenis dokumen tidak ditemukan"}
+		}
+		return &uploadError{http.StatusInternalServerError, "Gagal mengunci jenis dokumen: " + err.Error()}
+	}
+
+	return checkUniqueDocumentType(tx, docType, employeeID, excludeID)
+}
+
+// createDocument menyimpan dokumen baru beserta klaim keunikan jenisnya dalam
+// satu transaksi.
+func createDocument(document *DocumentStaff, docType *document_type.DocumentType) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimDocumentType(tx, docType, document.EmployeeID, ""); err != nil {
+			return err
+		}
+
+		return tx.Create(document).Error
+	})
+}
+
+// checkDocumentTypeSize memeriksa ukuran file yang tersimpan (setelah
+// normalisasi gambar) terhadap batas jenis dokumen.
+func checkDocumentTypeSize(docType *document_type.DocumentType, size int64) error {
+	if docType == nil || docType.MaxSize == 0 || size <= docType.MaxSize {
+		return nil
+	}
+
+	return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Ukuran file melebihi batas %s untuk jenis dokumen %s", formatSize(docType.MaxSize), docType.Name)}
+}
+
+// documentSubject memakai nama jenis dokumen jika subject tidak diisi.
+func documentSubject(subject string, docType *document_type.DocumentType) string {
+	if subject == "" && docType != nil {
+		return docType.Name
+	}
+
+	return subject
+}
+
+func documentTypeID(docType *document_type.DocumentType) string {
+	if docType == nil {
+		return ""
+	}
+
+	return docType.ID
+}
+
+// uploadErrorStatus mengembalikan status HTTP uploadError, atau 500 untuk
+// error lain.
+func uploadErrorStatus(err error) int {
+	if uploadErr, ok := err.(*uploadError); ok {
+		return uploadErr.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+func respondUploadError(c *gin.Context, err error) {
+	c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+func formatSize(bytes int64) string {
+	if bytes < 1<<20 {
+		return fmt.Sprintf("%d KB", bytes>>10)
+	}
+
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
+
+// documentTypeGroup adalah jumlah dokumen per jenis pada listing dengan
+// group_by=document_type. DocumentTypeID nil berarti dokumen tanpa jenis.
+type documentTypeGroup struct {
+	DocumentTypeID *string `json:"document_type_id"`
+	Code           string  `json:"code"`
+	Name           string  `json:"name"`
+	Count          int64   `json:"count"`
+}
+
+// applyDocumentTypeFilter menerapkan filter document_type_id pada query
+// document_staffs. Nilai "none" memilih dokumen yang belum memiliki jenis.
+func applyDocumentTypeFilter(c *gin.Context, query *gorm.DB) *gorm.DB {
+	switch documentTypeID := c.Query("document_type_id"); documentTypeID {
+	case "":
+		return query
+	case "none":
+		return query.Where("(document_staffs.document_type_id IS NULL OR document_staffs.document_type_id = '')")
+	default:
+		return query.Where("document_staffs.document_type_id = ?", documentTypeID)
+	}
+}
+
+// groupByDocumentType bernilai true jika listing diminta dengan
+// group_by=document_type.
+func groupByDocumentType(c *gin.Context) bool {
+	return c.Query("group_by") == "document_type"
+}
+
+// documentTypeGroups menghitung jumlah dokumen per jenis dari query listing
+// yang sudah difilter (harus sudah LEFT JOIN document_types). Query asal
+// tidak berubah.
+func documentTypeGroups(query *gorm.DB) ([]documentTypeGroup, error) {
+	groups := []documentTypeGroup{}
+	err := query.Session(&gorm.Session{}).
+		Select(`NULLIF(document_staffs.document_type_id, '') AS document_type_id,
+				COALESCE(document_types.code, '') AS code,
+				COALESCE(document_types.name, '') AS name,
+				COUNT(*) AS count`).
+		Group("NULLIF(document_staffs.document_type_id, ''), document_types.code, document_types.name").
+		Order("name IS NULL, name = '', name ASC").
+		Scan(&groups).Error
+
+	return groups, err
+}
+
+// documentTypeSummary adalah field document_type pada item listing.
+func documentTypeSummary(id, code, name *string) gin.H {
+	if id == nil || *id == "" || code == nil {
+		return nil
+	}
+
+	return gin.H{"id": *id, "code": *code, "name": *name}
+}
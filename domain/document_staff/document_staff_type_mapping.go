@@ -0,0 +1,169 @@
+package document_staff
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// typeMappingBatch adalah jumlah id per UPDATE saat pemetaan diterapkan.
+const typeMappingBatch = 500
+
+// TypeMappingItem adalah dokumen yang tidak dipetakan otomatis.
+type TypeMappingItem struct {
+	DocumentID string   `json:"document_id"`
+	EmployeeID string   `json:"employee_id,omitempty"`
+	Subject    string   `json:"subject"`
+	Matches    []string `json:"matches"`
+}
+
+// TypeMappingReport adalah hasil MapDocumentTypes. Unmatched berisi subject
+// yang belum cocok dengan pola mana pun beserta jumlah dokumennya, sebagai
+// bahan menyusun pola baru.
+type TypeMappingReport struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Applied    bool              `json:"applied"`
+	Scanned    int               `json:"scanned"`
+	Mapped     int               `json:"mapped"`
+	ByType     map[string]int    `json:"by_type"`
+	Ambiguous  []TypeMappingItem `json:"ambiguous"`
+	Duplicates []TypeMappingItem `json:"duplicates"`
+	Unmatched  map[string]int    `json:"unmatched_subjects"`
+}
+
+// MapDocumentTypes memetakan subject dokumen aktif yang belum memiliki jenis
+// ke jenis dokumen berdasarkan pola yang diatur admin. Subject yang cocok
+// dengan lebih dari satu jenis tidak dipetakan. Untuk jenis yang unik per
+// pegawai, hanya dokumen terbaru yang dipetakan dan sisanya dilaporkan
+// sebagai duplikat. Ekstensi dan ukuran file lama tidak diperiksa. Tanpa
+// apply, hanya laporan yang dibuat.
+func MapDocumentTypes(apply bool) (TypeMappingReport, error) {
+	report := TypeMappingReport{
+		StartedAt:  time.Now(),
+		Applied:    apply,
+		ByType:     map[string]int{},
+		Ambiguous:  []TypeMappingItem{},
+		Duplicates: []TypeMappingItem{},
+		Unmatched:  map[string]int{},
+	}
+
+	matcher, err := document_type.NewSubjectMatcher()
+	if err != nil {
+		return report, err
+	}
+
+	// Pasangan jenis unik dan pegawai yang sudah memiliki dokumen.
+	var owned []struct {
+		DocumentTypeID string
+		EmployeeID     string
+	}
+	if err := database.DB.Model(&DocumentStaff{}).
+		Distinct("document_type_id", "employee_id").
+		Where("document_type_id <> '' AND employee_id <> ''").
+		Scan(&owned).Error; err != nil {
+		return report, err
+	}
+
+	claimed := map[string]bool{}
+	for _, pair := range owned {
+		claimed[pair.DocumentTypeID+"/"+pair.EmployeeID] = true
+	}
+
+	var documents []DocumentStaff
+	if err := database.DB.
+		Select("id", "employee_id", "subject").
+		Where("document_type_id IS NULL OR document_type_id = ''").
+		Order("created_at DESC").
+		Find(&documents).Error; err != nil {
+		return report, err
+	}
+
+	mapped := map[string][]string{}
+	for _, document := range documents {
+		report.Scanned++
+
+		matches := matcher.Match(document.Subject)
+		switch len(matches) {
+		case 0:
+			report.Unmatched[strings.TrimSpace(document.Subject)]++
+			continue
+		case 1:
+		default:
+			item := TypeMappingItem{DocumentID: document.ID, EmployeeID: document.EmployeeID, Subject: document.Subject}
+			for _, match := range matches {
+				item.Matches = append(item.Matches, match.Code)
+			}
+			report.Ambiguous = append(report.Ambiguous, item)
+			continue
+		}
+
+		docType := matches[0]
+		if docType.UniquePerEmployee && document.EmployeeID != "" {
+			key := docType.ID + "/" + document.EmployeeID
+			if claimed[key] {
+				report.Duplicates = append(report.Duplicates, TypeMappingItem{
+					DocumentID: document.ID,
+					EmployeeID: document.EmployeeID,
+					Subject:    document.Subject,
+					Matches:    []string{docType.Code},
+				})
+				continue
+			}
+			claimed[key] = true
+		}
+
+		mapped[docType.ID] = append(mapped[docType.ID], document.ID)
+		report.ByType[docType.Code]++
+		report.Mapped++
+	}
+
+	if apply {
+		for typeID, documentIDs := range mapped {
+			for start := 0; start < len(documentIDs); start += typeMappingBatch {
+				batch := documentIDs[start:min(len(documentIDs), start+typeMappingBatch)]
+				if err := database.DB.Model(&DocumentStaff{}).
+					Where("id IN ?", batch).
+					UpdateColumn("document_type_id", typeID).Error; err != nil {
+					return report, err
+				}
+
+				for _, documentID := range batch {
+					syncSearchIndex(documentID)
+				}
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// ======================================================
+// MAP SUBJECTS TO DOCUMENT TYPES - ADMIN ONLY
+// ======================================================
+// Tanpa apply=true hanya menampilkan hasil pemetaan (dry run).
+func MapDocumentTypesAdmin(c *gin.Context) {
+	apply := c.Query("apply") == "true"
+
+	report, err := MapDocumentTypes(apply)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memetakan jenis dokumen: " + err.Error()})
+		return
+	}
+
+	message := "Pratinjau pemetaan jenis dokumen"
+	if apply {
+		message = "Pemetaan jenis dokumen diterapkan"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"report":  report,
+	})
+}
@@ -0,0 +1,399 @@
+package document_staff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"BackendKantorDinsos/infrastructure/filecheck"
+	"BackendKantorDinsos/infrastructure/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxFormValueSize = 64 << 10
+
+// prepareFields adalah field teks yang dibaca prepareUpload sehingga harus
+// dikirim sebelum part file.
+var prepareFields = map[string]bool{
+	"subject":          true,
+	"employee_id":      true,
+	"user_id":          true,
+	"document_type_id": true,
+}
+
+var errFileTooLarge = errors.New("ukuran file melebihi batas")
+
+// uploadForm adalah hasil pembacaan multipart form secara streaming.
+// File sudah tersimpan di storage ketika form selesai dibaca.
+type uploadForm struct {
+	fields url.Values
+	file   *uploadedFile
+
+	// spooled berisi part "files" yang ditampung di file sementara untuk
+	// diupload bersamaan, hanya diisi oleh streamMultiUpload.
+	spooled  []*spooledFile
+	multiple bool
+}
+
+type uploadedFile struct {
+	FileName     string
+	ResourceType string
+	MimeType     string
+	Checksum     string
+	Object       storage.Object
+	Size         int64
+
+	// OriginalSize dan OriginalChecksum adalah ukuran dan SHA-256 file
+	// sebelum gambar dinormalisasi (sama dengan Size dan Checksum untuk file
+	// yang disimpan apa adanya), dan OriginalPublicID file aslinya jika
+	// IMAGE_KEEP_ORIGINAL aktif.
+	OriginalSize     int64
+	OriginalChecksum string
+	OriginalPublicID string
+}
+
+// prepareUpload dipanggil tepat sebelum part file dikirim ke storage, dengan
+// field yang sudah terbaca sejauh ini dan nama file dari client. Fungsi ini
+// memvalidasi field dan mengembalikan owner id untuk penataan folder di
+// storage. uploadError yang dikembalikan dikirim dengan status HTTP-nya.
+type prepareUpload func(fields url.Values, fileName string) (string, error)
+
+// streamUpload membaca multipart form part demi part dan mengalirkan part
+// "file" langsung ke storage tanpa menampung seluruh isi file di memori.
+// Field teks yang dibutuhkan untuk validasi (subject, employee_id, user_id,
+// document_type_id) harus dikirim sebelum part file; field tersebut yang
+// datang setelah file ditolak dengan pesan yang menjelaskan urutannya. Jika
+// gagal, response error sudah dikirim dan file yang terlanjur tersimpan
+// dihapus kembali.
+func streamUpload(c *gin.Context, prepare prepareUpload) (*uploadForm, bool) {
+	return readUploadForm(c, prepare, false)
+}
+
+// streamMultiUpload sama dengan streamUpload, tetapi juga menerima banyak
+// part "files". Part tersebut ditampung di file sementara yang harus
+// dibersihkan pemanggil dengan cleanup.
+func streamMultiUpload(c *gin.Context, prepare prepareUpload) (*uploadForm, bool) {
+	return readUploadForm(c, prepare, true)
+}
+
+func readUploadForm(c *gin.Context, prepare prepareUpload, multiple bool) (*uploadForm, bool) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request harus berupa multipart/form-data"})
+		return nil, false
+	}
+
+	form := &uploadForm{fields: url.Values{}, multiple: multiple}
+
+	fail := func(status int, message string) (*uploadForm, bool) {
+		form.discard()
+		form.cleanup()
+		c.JSON(status, gin.H{"error": message})
+		return nil, false
+	}
+
+	failWith := func(err error, status int) (*uploadForm, bool) {
+		var uploadErr *uploadError
+		if errors.As(err, &uploadErr) {
+			return fail(uploadErr.status, uploadErr.message)
+		}
+		return fail(status, err.Error())
+	}
+
+	// Jika validasi sebelum file gagal, sisa form tetap dibaca agar field
+	// yang terlambat dikirim bisa dilaporkan sebagai masalah urutan.
+	var prepareErr error
+	fileSeen := false
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(http.StatusBadRequest, "Gagal membaca form: "+err.Error())
+		}
+
+		name := part.FormName()
+
+		if part.FileName() == "" {
+			if fileSeen && prepareFields[name] {
+				part.Close()
+				return fail(http.StatusBadRequest, fmt.Sprintf("Field %s harus dikirim sebelum file pada multipart form", name))
+			}
+
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize))
+			part.Close()
+			if err != nil {
+				return fail(http.StatusBadRequest, "Gagal membaca form: "+err.Error())
+			}
+			form.fields.Add(name, string(value))
+			continue
+		}
+
+		if prepareErr != nil {
+			part.Close()
+			continue
+		}
+
+		if name == "files" && form.multiple {
+			if len(form.spooled) >= maxFilesPerRequest() {
+				part.Close()
+				return fail(http.StatusBadRequest, fmt.Sprintf("Maksimal %d file per request", maxFilesPerRequest()))
+			}
+
+			spooled, err := spoolPart(part)
+			part.Close()
+			if err != nil {
+				return fail(http.StatusInternalServerError, "Gagal menampung file: "+err.Error())
+			}
+
+			form.spooled = append(form.spooled, spooled)
+			continue
+		}
+
+		if name != "file" {
+			part.Close()
+			continue
+		}
+
+		if fileSeen {
+			part.Close()
+			return fail(http.StatusBadRequest, "Hanya satu file yang dapat diupload")
+		}
+		fileSeen = true
+
+		fileName := filepath.Base(part.FileName())
+
+		owner, err := prepare(form.fields, fileName)
+		if err != nil {
+			part.Close()
+			prepareErr = err
+			continue
+		}
+
+		file, err := saveFile(part, fileName, owner)
+		part.Close()
+		if err != nil {
+			return failWith(err, http.StatusInternalServerError)
+		}
+
+		form.file = file
+	}
+
+	if prepareErr != nil {
+		return failWith(prepareErr, http.StatusBadRequest)
+	}
+
+	return form, true
+}
+
+// uploadError membawa status HTTP dan pesan untuk client.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// saveFile memeriksa isi file, menerapkan batas ukuran selama stream, lalu
+// menyimpannya ke storage. Jika antivirus aktif, file ditampung dulu di file
+// sementara sambil dialirkan ke clamd dan baru dikirim ke storage setelah
+// dinyatakan bersih, sehingga file terinfeksi tidak pernah tersimpan. Tanpa
+// antivirus file langsung dialirkan ke storage; file yang ditolak setelah
+// tersimpan langsung dijadwalkan untuk dihapus.
+func saveFile(r io.Reader, fileName, owner string) (*uploadedFile, error) {
+	inspector, err := filecheck.NewInspector(r, fileName)
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, inspectionMessage(err)}
+	}
+
+	resourceType, folder := resolveResourceType(inspector.MimeType())
+	limit := maxUploadSize(resourceType)
+	limited := &limitedReader{r: inspector, remaining: limit}
+	hasher := sha256.New()
+
+	scan, err := startScan(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if scan != nil {
+		defer scan.Close()
+	}
+
+	if resourceType == "image" && imageNormalization() {
+		return saveNormalizedImage(inspector, limited, scan, fileName, folder, owner)
+	}
+
+	var content io.Reader = io.TeeReader(limited, hasher)
+	if scan != nil {
+		spool, err := os.CreateTemp("", "upload-scan-*")
+		if err != nil {
+			return nil, &uploadError{http.StatusInternalServerError, "Gagal menampung file: " + err.Error()}
+		}
+		defer func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}()
+
+		_, err = io.Copy(spool, io.TeeReader(limited, io.MultiWriter(hasher, scan)))
+		if limited.exceeded {
+			return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Ukuran file melebihi batas %d MB", limit>>20)}
+		}
+		if err != nil {
+			return nil, &uploadError{http.StatusBadRequest, "Gagal membaca file: " + err.Error()}
+		}
+
+		if err := inspector.Verdict(); err != nil {
+			return nil, &uploadError{http.StatusBadRequest, inspectionMessage(err)}
+		}
+
+		if err := finishScan(scan, fileName, owner); err != nil {
+			return nil, err
+		}
+		scan = nil
+
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return nil, &uploadError{http.StatusInternalServerError, "Gagal membaca file sementara: " + err.Error()}
+		}
+		content = spool
+	}
+
+	object, err := store.Put(content, storage.PutOptions{
+		FileName:     fileName,
+		Folder:       folder,
+		ResourceType: resourceType,
+		OwnerID:      owner,
+	})
+
+	if limited.exceeded {
+		if err == nil {
+			scheduleStorageDeletion(object.PublicID, resourceType)
+		}
+		return nil, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Ukuran file melebihi batas %d MB", limit>>20)}
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, "Upload gagal: " + err.Error()}
+	}
+
+	if err := inspector.Verdict(); err != nil {
+		scheduleStorageDeletion(object.PublicID, resourceType)
+		return nil, &uploadError{http.StatusBadRequest, inspectionMessage(err)}
+	}
+
+	if err := finishScan(scan, fileName, owner); err != nil {
+		scheduleStorageDeletion(object.PublicID, resourceType)
+		return nil, err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	return &uploadedFile{
+		FileName:         fileName,
+		ResourceType:     resourceType,
+		MimeType:         inspector.MimeType(),
+		Checksum:         checksum,
+		Object:           object,
+		Size:             limited.read,
+		OriginalSize:     limited.read,
+		OriginalChecksum: checksum,
+	}, nil
+}
+
+// discard menjadwalkan penghapusan file yang sudah tersimpan ketika proses
+// setelah upload (misalnya penyimpanan ke database) gagal.
+func (f *uploadForm) discard() {
+	if f.file == nil {
+		return
+	}
+
+	scheduleStorageDeletion(f.file.Object.PublicID, f.file.ResourceType)
+	if f.file.OriginalPublicID != "" {
+		scheduleStorageDeletion(f.file.OriginalPublicID, f.file.ResourceType)
+	}
+}
+
+// resolveResourceType menentukan resource type dan folder storage dari MIME
+// type hasil pemeriksaan isi file.
+func resolveResourceType(mimeType string) (string, string) {
+	if strings.HasPrefix(mimeType, "image/") {
+		return "image", "gambar"
+	}
+
+	return "raw", "document_staff"
+}
+
+// inspectionMessage mengubah error pemeriksaan isi file menjadi pesan untuk
+// client.
+func inspectionMessage(err error) string {
+	switch {
+	case errors.Is(err, filecheck.ErrUnsupported):
+		return "Format file tidak didukung"
+	case errors.Is(err, filecheck.ErrMismatch):
+		return "Isi file tidak sesuai dengan ekstensi"
+	case errors.Is(err, filecheck.ErrMacro):
+		return "Dokumen Office yang mengandung macro tidak diizinkan"
+	case errors.Is(err, filecheck.ErrJavaScript):
+		return "PDF yang mengandung JavaScript tidak diizinkan"
+	case errors.Is(err, filecheck.ErrUnverifiable):
+		return "PDF tidak dapat diperiksa (terenkripsi atau memakai kompresi yang tidak didukung)"
+	default:
+		return "Gagal memeriksa file: " + err.Error()
+	}
+}
+
+// maxUploadSize mengembalikan batas ukuran file per resource type dalam byte,
+// diatur lewat env UPLOAD_MAX_IMAGE_MB dan UPLOAD_MAX_RAW_MB.
+func maxUploadSize(resourceType string) int64 {
+	env, fallback := "UPLOAD_MAX_RAW_MB", int64(100)
+	if resourceType == "image" {
+		env, fallback = "UPLOAD_MAX_IMAGE_MB", 10
+	}
+
+	if mb, err := strconv.ParseInt(os.Getenv(env), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+
+	return fallback << 20
+}
+
+// limitedReader menghentikan stream dengan errFileTooLarge begitu jumlah byte
+// melewati batas, sehingga upload ke storage ikut dibatalkan.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, errFileTooLarge
+	}
+
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return 0, errFileTooLarge
+	}
+
+	l.remaining -= int64(n)
+	l.read += int64(n)
+	return n, err
+}
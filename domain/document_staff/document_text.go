@@ -0,0 +1,657 @@
+package document_staff
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TextPending     = "pending"
+	TextDone        = "done"
+	TextFailed      = "failed"
+	TextUnsupported = "unsupported"
+)
+
+const (
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+)
+
+// extractableMimeTypes adalah MIME type yang punya extractor teks. Bersama
+// gambar, dokumen dengan tipe ini yang pernah berstatus unsupported dicoba
+// lagi oleh ExtractTexts.
+var extractableMimeTypes = []string{"application/pdf", mimeDOCX, mimeXLSX, mimePPTX}
+
+// ooxmlEntryLimit membatasi ukuran satu file XML di dalam docx/xlsx/pptx
+// setelah didekompresi, untuk mencegah zip bomb.
+const ooxmlEntryLimit = 64 << 20
+
+// errNoExtractor dikembalikan jika tipe file tidak bisa diekstrak teksnya
+// atau pdftotext tidak terpasang.
+var errNoExtractor = errors.New("ekstraksi teks tidak tersedia untuk file ini")
+
+// DocumentText menyimpan teks hasil ekstraksi file dokumen yang aktif.
+// PublicID mencatat file mana yang diekstrak, sehingga teks lama tidak
+// tertukar dengan file pengganti. Confidence (0-100) hanya terisi untuk hasil
+// OCR; DurationMs adalah lama pemrosesan.
+type DocumentText struct {
+	DocumentID  string     `gorm:"type:char(36);primaryKey" json:"document_id"`
+	PublicID    string     `gorm:"type:varchar(255)" json:"public_id"`
+	Status      string     `gorm:"type:varchar(20);index" json:"status"`
+	Method      string     `gorm:"type:varchar(20)" json:"method"`
+	Content     string     `gorm:"type:longtext" json:"content"`
+	CharCount   int        `json:"char_count"`
+	Truncated   bool       `json:"truncated"`
+	Confidence  *float64   `json:"confidence"`
+	DurationMs  int64      `json:"duration_ms"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	ExtractedAt *time.Time `json:"extracted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// textMaxChars membatasi panjang teks yang disimpan, diatur lewat env
+// TEXT_MAX_CHARS (default 1.000.000 karakter).
+func textMaxChars() int {
+	if n, err := strconv.Atoi(os.Getenv("TEXT_MAX_CHARS")); err == nil && n > 0 {
+		return n
+	}
+
+	return 1_000_000
+}
+
+var textQueue = make(chan string, 256)
+
+// queueFileProcessing menjadwalkan semua pemrosesan background untuk file
+// dokumen yang baru diupload atau diganti. Metadata langsung masuk index
+// pencarian; isi teksnya menyusul setelah ekstraksi selesai.
+func queueFileProcessing(documentID string) {
+	syncSearchIndex(documentID)
+	queueThumbnail(documentID)
+	queueTextExtraction(documentID)
+}
+
+// queueTextExtraction menjadwalkan ekstraksi teks tanpa menahan request. Jika
+// antrean penuh, dokumen dilewati dan bisa diekstrak ulang lewat perintah
+// "extract-text".
+func queueTextExtraction(documentID string) {
+	select {
+	case textQueue <- documentID:
+	default:
+		log.Printf("⚠️ Antrean ekstraksi teks penuh, dokumen %s dilewati", documentID)
+	}
+}
+
+// StartTextWorker memproses antrean ekstraksi teks di background.
+func StartTextWorker() {
+	go func() {
+		for documentID := range textQueue {
+			needsOCR, err := extractDocumentText(documentID)
+			if err != nil {
+				log.Printf("⚠️ Gagal mengekstrak teks dokumen %s: %v", documentID, err)
+				continue
+			}
+			if needsOCR {
+				queueOCR(documentID)
+			}
+		}
+	}()
+}
+
+// extractDocumentText mengekstrak teks file dokumen saat ini dan menyimpan
+// hasilnya, termasuk status gagal atau tidak didukung. Gambar dan PDF tanpa
+// lapisan teks disimpan dengan status pending dan mengembalikan true agar
+// dilanjutkan ke OCR. Error hanya dikembalikan jika hasil tidak dapat
+// disimpan.
+func extractDocumentText(documentID string) (bool, error) {
+	var document DocumentStaff
+	if err := database.DB.First(&document, "id = ?", documentID).Error; err != nil {
+		return false, err
+	}
+
+	if document.PublicID == "" {
+		return false, nil
+	}
+
+	if err := fillMimeType(&document); err != nil {
+		log.Printf("⚠️ Gagal menyimpan MIME type dokumen %s: %v", document.ID, err)
+	}
+
+	text := DocumentText{
+		DocumentID: document.ID,
+		PublicID:   document.PublicID,
+		Status:     TextPending,
+	}
+
+	start := time.Now()
+	content, method, err := extractText(document)
+	now := time.Now()
+	text.Method = method
+	text.ExtractedAt = &now
+	text.DurationMs = now.Sub(start).Milliseconds()
+
+	ocr := needsOCR(document.MimeType, content, err)
+
+	switch {
+	case ocr:
+		// Teks yang sedikit (misalnya hanya kop surat) tetap disimpan
+		// sampai hasil OCR menggantikannya.
+		text.Method = methodOCR
+		text.Content, text.Truncated = truncateText(content, textMaxChars())
+		text.CharCount = utf8.RuneCountInString(text.Content)
+	case errors.Is(err, errNoExtractor):
+		text.Status = TextUnsupported
+		text.Error = err.Error()
+	case err != nil:
+		text.Status = TextFailed
+		text.Error = err.Error()
+	default:
+		text.Status = TextDone
+		text.Content, text.Truncated = truncateText(content, textMaxChars())
+		text.CharCount = utf8.RuneCountInString(text.Content)
+	}
+
+	saved, err := saveDocumentText(text)
+	if err != nil || !saved {
+		return false, err
+	}
+
+	syncSearchIndex(document.ID)
+	return ocr, nil
+}
+
+// saveDocumentText menyimpan hasil ekstraksi hanya jika file dokumen belum
+// diganti selama proses. Mengembalikan false jika hasil dibuang.
+func saveDocumentText(text DocumentText) (bool, error) {
+	var current int64
+	database.DB.Model(&DocumentStaff{}).
+		Where("id = ? AND public_id = ?", text.DocumentID, text.PublicID).
+		Count(&current)
+	if current == 0 {
+		return false, nil
+	}
+
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&text).Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// fillMimeType mengisi MIME type dokumen lama yang kosong dari ekstensi
+// nama filenya dan menyimpannya, agar ekstraksi teks, OCR dan filter
+// pencarian memakai jenis file yang benar.
+func fillMimeType(document *DocumentStaff) error {
+	if document.MimeType != "" {
+		return nil
+	}
+
+	mimeType := documentMimeType(*document)
+	if mimeType == "" {
+		return nil
+	}
+
+	document.MimeType = mimeType
+	return database.DB.Model(&DocumentStaff{}).
+		Where("id = ? AND (mime_type IS NULL OR mime_type = '')", document.ID).
+		UpdateColumn("mime_type", mimeType).Error
+}
+
+// fillMimeTypes menjalankan fillMimeType untuk semua dokumen yang MIME
+// type-nya masih kosong.
+func fillMimeTypes() error {
+	var documents []DocumentStaff
+	if err := database.DB.Select("id", "file_name", "public_id", "mime_type").
+		Where("public_id <> '' AND (mime_type IS NULL OR mime_type = '')").
+		Find(&documents).Error; err != nil {
+		return err
+	}
+
+	for i := range documents {
+		if err := fillMimeType(&documents[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractText memilih metode ekstraksi berdasarkan MIME type dokumen.
+func extractText(document DocumentStaff) (string, string, error) {
+	var method string
+	switch document.MimeType {
+	case "application/pdf":
+		method = "pdftotext"
+	case mimeDOCX, mimeXLSX, mimePPTX:
+		method = "ooxml"
+	default:
+		return "", "", errNoExtractor
+	}
+
+	reader, err := store.Open(document.PublicID, document.ResourceType)
+	if err != nil {
+		return "", method, fmt.Errorf("file tidak dapat dibuka: %v", err)
+	}
+	defer reader.Close()
+
+	// pdftotext dan archive/zip butuh file yang bisa dibaca acak.
+	tmp, err := os.CreateTemp("", "text-*")
+	if err != nil {
+		return "", method, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(reader, maxUploadSize("raw")))
+	if err != nil {
+		return "", method, fmt.Errorf("gagal membaca file: %v", err)
+	}
+
+	var text string
+	if method == "pdftotext" {
+		text, err = pdfText(tmp.Name())
+	} else {
+		text, err = ooxmlText(tmp, size, document.MimeType)
+	}
+
+	return normalizeText(text), method, err
+}
+
+// pdfText menjalankan pdftotext (poppler-utils).
+func pdfText(path string) (string, error) {
+	binary, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return "", fmt.Errorf("%w: pdftotext tidak terpasang", errNoExtractor)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "-q", "-enc", "UTF-8", "-layout", path, "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("pdftotext gagal: %s", message)
+		}
+		return "", fmt.Errorf("pdftotext gagal: %v", err)
+	}
+
+	return stdout.String(), nil
+}
+
+// ooxmlText membaca teks dari XML di dalam docx, xlsx atau pptx.
+func ooxmlText(r io.ReaderAt, size int64, mimeType string) (string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("arsip Office tidak valid: %v", err)
+	}
+
+	entries := map[string]*zip.File{}
+	for _, f := range archive.File {
+		entries[f.Name] = f
+	}
+
+	var out strings.Builder
+
+	switch mimeType {
+	case mimeDOCX:
+		// Isi utama lebih dulu, lalu header, footer dan catatan kaki.
+		names := []string{"word/document.xml"}
+		names = append(names, sortedEntries(entries, "word/header")...)
+		names = append(names, sortedEntries(entries, "word/footer")...)
+		names = append(names, "word/footnotes.xml", "word/endnotes.xml")
+
+		for _, name := range names {
+			if err := appendXMLText(&out, entries[name], "t", "p", "tab"); err != nil {
+				return "", err
+			}
+		}
+	case mimePPTX:
+		for _, name := range sortedEntries(entries, "ppt/slides/slide") {
+			if err := appendXMLText(&out, entries[name], "t", "p", ""); err != nil {
+				return "", err
+			}
+			out.WriteString("\n")
+		}
+	case mimeXLSX:
+		if err := xlsxText(&out, entries); err != nil {
+			return "", err
+		}
+	}
+
+	return out.String(), nil
+}
+
+// appendXMLText menyalin isi elemen textTag dan menambahkan baris baru setiap
+// akhir elemen breakTag. Nama elemen dicocokkan tanpa namespace.
+func appendXMLText(out *strings.Builder, f *zip.File, textTag, breakTag, tabTag string) error {
+	if f == nil {
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, ooxmlEntryLimit))
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("gagal membaca %s: %v", f.Name, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case textTag:
+				inText = true
+			case tabTag:
+				out.WriteString("\t")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case textTag:
+				inText = false
+			case breakTag:
+				out.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				out.Write(t)
+			}
+		}
+	}
+}
+
+// xlsxText menulis isi sel per baris, dipisah tab. Nilai string diambil dari
+// sharedStrings.xml.
+func xlsxText(out *strings.Builder, entries map[string]*zip.File) error {
+	shared, err := xlsxSharedStrings(entries["xl/sharedStrings.xml"])
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedEntries(entries, "xl/worksheets/sheet") {
+		rc, err := entries[name].Open()
+		if err != nil {
+			return err
+		}
+
+		decoder := xml.NewDecoder(io.LimitReader(rc, ooxmlEntryLimit))
+		var cellType string
+		var inValue, firstCell bool
+
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rc.Close()
+				return fmt.Errorf("gagal membaca %s: %v", name, err)
+			}
+
+			switch t := token.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "row":
+					firstCell = true
+				case "c":
+					cellType = ""
+					for _, attr := range t.Attr {
+						if attr.Name.Local == "t" {
+							cellType = attr.Value
+						}
+					}
+				case "v", "t":
+					inValue = true
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "row":
+					out.WriteString("\n")
+				case "v", "t":
+					inValue = false
+				}
+			case xml.CharData:
+				if !inValue {
+					continue
+				}
+
+				value := string(t)
+				if cellType == "s" {
+					index, err := strconv.Atoi(strings.TrimSpace(value))
+					if err != nil || index < 0 || index >= len(shared) {
+						continue
+					}
+					value = shared[index]
+				}
+
+				if !firstCell {
+					out.WriteString("\t")
+				}
+				firstCell = false
+				out.WriteString(value)
+			}
+		}
+
+		rc.Close()
+		out.WriteString("\n")
+	}
+
+	return nil
+}
+
+// xlsxSharedStrings membaca tabel string bersama; satu elemen si bisa terdiri
+// dari beberapa run teks.
+func xlsxSharedStrings(f *zip.File) ([]string, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, ooxmlEntryLimit))
+	var shared []string
+	var current strings.Builder
+	inText := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gagal membaca %s: %v", f.Name, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				shared = append(shared, current.String())
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// sortedEntries mengembalikan nama entry zip berawalan prefix yang diurutkan
+// berdasarkan nomor urutnya (slide2 sebelum slide10).
+func sortedEntries(entries map[string]*zip.File, prefix string) []string {
+	var names []string
+	for name := range entries {
+		if strings.HasPrefix(name, prefix) && path.Ext(name) == ".xml" && !strings.Contains(strings.TrimPrefix(name, prefix), "/") {
+			names = append(names, name)
+		}
+	}
+
+	number := func(name string) int {
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".xml"))
+		return n
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return number(names[i]) < number(names[j])
+	})
+
+	return names
+}
+
+// normalizeText merapikan spasi berlebih agar teks lebih ringkas untuk
+// pencarian, dengan tetap mempertahankan baris.
+func normalizeText(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\f", "\n")
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func truncateText(text string, maxChars int) (string, bool) {
+	if utf8.RuneCountInString(text) <= maxChars {
+		return text, false
+	}
+
+	runes := []rune(text)
+	return string(runes[:maxChars]), true
+}
+
+// ======================================================
+// GET DOCUMENT TEXT - FOR ALL ROLES
+// ======================================================
+func GetDocumentText(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var text DocumentText
+	if err := database.DB.Where("document_id = ?", document.ID).Limit(1).Find(&text).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil teks dokumen: " + err.Error()})
+		return
+	}
+
+	if text.DocumentID == "" || text.PublicID != document.PublicID {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Teks dokumen belum diekstrak",
+			"text":    DocumentText{DocumentID: document.ID, PublicID: document.PublicID, Status: TextPending},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil teks dokumen",
+		"text":    text,
+	})
+}
+
+// ExtractTexts mengekstrak teks dokumen yang belum memiliki hasil untuk file
+// aktifnya, termasuk yang masih menunggu OCR. Dokumen lama yang MIME
+// type-nya kosong diisi lebih dulu dari ekstensi nama file, dan yang
+// sebelumnya berstatus unsupported dicoba lagi jika tipenya kini bisa
+// diekstrak. Jika all bernilai true, semua dokumen diekstrak ulang. OCR
+// dijalankan langsung, tidak lewat antrean. Mengembalikan jumlah dokumen
+// yang diproses.
+func ExtractTexts(all bool) (int, error) {
+	if err := fillMimeTypes(); err != nil {
+		return 0, err
+	}
+
+	query := database.DB.Model(&DocumentStaff{}).
+		Where("document_staffs.public_id <> ''")
+
+	if !all {
+		query = query.
+			Joins("LEFT JOIN document_texts ON document_texts.document_id = document_staffs.id").
+			Where("document_texts.document_id IS NULL OR document_texts.public_id <> document_staffs.public_id OR document_texts.status IN ? OR (document_texts.status = ? AND (document_staffs.mime_type IN ? OR document_staffs.mime_type LIKE 'image/%'))",
+				[]string{TextFailed, TextPending}, TextUnsupported, extractableMimeTypes)
+	}
+
+	var documentIDs []string
+	if err := query.Pluck("document_staffs.id", &documentIDs).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, documentID := range documentIDs {
+		needsOCR, err := extractDocumentText(documentID)
+		if err != nil {
+			log.Printf("⚠️ Gagal mengekstrak teks dokumen %s: %v", documentID, err)
+			continue
+		}
+
+		if needsOCR {
+			if err := ocrDocument(documentID); err != nil {
+				log.Printf("⚠️ Gagal menjalankan OCR dokumen %s: %v", documentID, err)
+				continue
+			}
+		}
+		processed++
+	}
+
+	return processed, nil
+}
@@ -0,0 +1,59 @@
+package document_staff
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DocumentVersion menyimpan file lama dari sebuah dokumen setiap kali file
+// diganti, sehingga file sebelumnya dapat diunduh atau dipulihkan.
+type DocumentVersion struct {
+	ID               string    `gorm:"type:char(36);primaryKey" json:"id"`
+	DocumentID       string    `gorm:"type:char(36);index" json:"document_id"`
+	FileName         string    `gorm:"type:varchar(500)" json:"file_name"`
+	FileURL          string    `gorm:"type:text" json:"file_url"`
+	PublicID         string    `gorm:"type:varchar(255)" json:"public_id"`
+	ResourceType     string    `gorm:"type:varchar(20)" json:"resource_type"`
+	MimeType         string    `gorm:"type:varchar(100)" json:"mime_type"`
+	Checksum         string    `gorm:"type:char(64)" json:"checksum"`
+	Size             int64     `json:"size"`
+	OriginalSize     int64     `json:"original_size"`
+	OriginalChecksum string    `gorm:"type:char(64)" json:"original_checksum"`
+	OriginalPublicID string    `gorm:"type:varchar(255)" json:"original_public_id,omitempty"`
+	UploadedBy       string    `gorm:"type:char(36)" json:"uploaded_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (v *DocumentVersion) BeforeCreate(tx *gorm.DB) (err error) {
+	if v.ID == "" {
+		v.ID = uuid.NewString()
+	}
+	return
+}
+
+// archiveCurrentFile menyimpan file dokumen yang sedang aktif sebagai versi
+// lama. Dipanggil di dalam transaksi yang sama dengan penggantian file.
+func archiveCurrentFile(tx *gorm.DB, document DocumentStaff) error {
+	if document.PublicID == "" {
+		return nil
+	}
+
+	version := DocumentVersion{
+		DocumentID:       document.ID,
+		FileName:         document.FileName,
+		FileURL:          document.FileURL,
+		PublicID:         document.PublicID,
+		ResourceType:     document.ResourceType,
+		MimeType:         document.MimeType,
+		Checksum:         document.Checksum,
+		Size:             document.Size,
+		OriginalSize:     document.OriginalSize,
+		OriginalChecksum: document.OriginalChecksum,
+		OriginalPublicID: document.OriginalPublicID,
+		UploadedBy:       document.UploadedBy,
+	}
+
+	return tx.Create(&version).Error
+}
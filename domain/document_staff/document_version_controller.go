@@ -0,0 +1,136 @@
+package document_staff
+
+import (
+	"net/http"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ======================================================
+// GET DOCUMENT VERSIONS - FOR ALL ROLES
+// ======================================================
+func GetDocumentVersions(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var versions []DocumentVersion
+	if err := database.DB.
+		Where("document_id = ?", document.ID).
+		Order("created_at DESC").
+		Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil versi dokumen: " + err.Error()})
+		return
+	}
+
+	for i := range versions {
+		versions[i].FileURL = resolveFileURL(versions[i].FileURL, versions[i].PublicID, versions[i].ResourceType)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil versi dokumen",
+		"data": gin.H{
+			"document": withSignedURL(document),
+			"versions": versions,
+		},
+	})
+}
+
+// ======================================================
+// DOWNLOAD DOCUMENT VERSION - FOR ALL ROLES
+// ======================================================
+func DownloadDocumentVersion(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var version DocumentVersion
+	if err := database.DB.First(&version, "id = ? AND document_id = ?", c.Param("versionId"), document.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versi dokumen tidak ditemukan"})
+		return
+	}
+
+	serveStoredFile(c, storedFile{
+		PublicID:     version.PublicID,
+		ResourceType: version.ResourceType,
+		FileName:     version.FileName,
+		MimeType:     version.MimeType,
+		Checksum:     version.Checksum,
+		ModTime:      version.CreatedAt,
+		OnOpen: func() {
+			recordAccess(c, document.ID, version.ID, accessVersionDownload)
+		},
+	})
+}
+
+// ======================================================
+// ROLLBACK DOCUMENT VERSION - FOR ALL ROLES
+// ======================================================
+func RollbackDocumentVersion(c *gin.Context) {
+	document, ok := findAccessibleDocument(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var version DocumentVersion
+	if err := database.DB.First(&version, "id = ? AND document_id = ?", c.Param("versionId"), document.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Versi dokumen tidak ditemukan"})
+		return
+	}
+
+	tx := database.DB.Begin()
+
+	// File yang aktif sekarang ikut masuk riwayat, sehingga rollback juga
+	// dapat dibatalkan.
+	if err := archiveCurrentFile(tx, document); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan versi dokumen saat ini: " + err.Error()})
+		return
+	}
+
+	document.FileName = version.FileName
+	document.FileURL = version.FileURL
+	document.PublicID = version.PublicID
+	document.ResourceType = version.ResourceType
+	document.MimeType = version.MimeType
+	document.Checksum = version.Checksum
+	document.Size = version.Size
+	document.OriginalSize = version.OriginalSize
+	document.OriginalChecksum = version.OriginalChecksum
+	document.OriginalPublicID = version.OriginalPublicID
+	document.UploadedBy = version.UploadedBy
+
+	if err := resetThumbnail(tx, &document); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan dokumen: " + err.Error()})
+		return
+	}
+
+	if err := tx.Save(&document).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan dokumen: " + err.Error()})
+		return
+	}
+
+	if err := tx.Delete(&version).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui riwayat versi: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan dokumen: " + err.Error()})
+		return
+	}
+
+	queueFileProcessing(document.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Dokumen berhasil dipulihkan ke versi sebelumnya",
+		"document": withSignedURL(document),
+	})
+}
@@ -0,0 +1,183 @@
+package document_staff
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DeletionPending = "pending"
+	DeletionDone    = "done"
+	DeletionDead    = "dead"
+)
+
+// StorageDeletion adalah outbox penghapusan file di storage. Baris dicatat
+// di transaksi yang sama dengan perubahan data dokumen, lalu diproses oleh
+// worker dengan retry dan exponential backoff.
+type StorageDeletion struct {
+	ID            string    `gorm:"type:char(36);primaryKey" json:"id"`
+	PublicID      string    `gorm:"type:varchar(255)" json:"public_id"`
+	ResourceType  string    `gorm:"type:varchar(20)" json:"resource_type"`
+	Status        string    `gorm:"type:varchar(20);index;default:'pending'" json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `gorm:"index" json:"next_attempt_at"`
+	LastError     string    `gorm:"type:text" json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (d *StorageDeletion) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	return
+}
+
+// enqueueStorageDeletion mencatat file yang harus dihapus dari storage.
+// Gunakan tx yang sama dengan perubahan baris dokumen.
+func enqueueStorageDeletion(tx *gorm.DB, publicID, resourceType string) error {
+	if publicID == "" {
+		return nil
+	}
+
+	return tx.Create(&StorageDeletion{
+		PublicID:      publicID,
+		ResourceType:  resourceType,
+		Status:        DeletionPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// scheduleStorageDeletion dipakai untuk file yang tidak punya baris database
+// (misalnya upload yang gagal disimpan). Jika outbox tidak bisa dicatat,
+// file langsung dihapus.
+func scheduleStorageDeletion(publicID, resourceType string) {
+	if err := enqueueStorageDeletion(database.DB, publicID, resourceType); err == nil {
+		return
+	}
+
+	if err := store.Delete(publicID, resourceType); err != nil {
+		log.Printf("⚠️ Gagal menghapus file %s dari storage: %v", publicID, err)
+	}
+}
+
+// maxDeletionAttempts diatur lewat env STORAGE_DELETE_MAX_ATTEMPTS. Default 8.
+func maxDeletionAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("STORAGE_DELETE_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 8
+}
+
+// deletionBackoff: 30 detik, 1 menit, 2 menit, ... maksimal 6 jam.
+func deletionBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= 6*time.Hour {
+			return 6 * time.Hour
+		}
+	}
+	return backoff
+}
+
+// ProcessStorageDeletions memproses outbox yang sudah jatuh tempo dan
+// mengembalikan jumlah file yang berhasil dihapus.
+func ProcessStorageDeletions() (int, error) {
+	var deletions []StorageDeletion
+	if err := database.DB.
+		Where("status = ? AND next_attempt_at <= ?", DeletionPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(50).
+		Find(&deletions).Error; err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, deletion := range deletions {
+		// Klaim baris dengan memajukan next_attempt_at agar tidak diproses
+		// ganda oleh instance lain.
+		claim := database.DB.Model(&StorageDeletion{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", deletion.ID, DeletionPending, time.Now()).
+			Update("next_attempt_at", time.Now().Add(5*time.Minute))
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		deletion.Attempts++
+		updates := map[string]interface{}{"attempts": deletion.Attempts}
+
+		if err := store.Delete(deletion.PublicID, deletion.ResourceType); err != nil {
+			updates["last_error"] = err.Error()
+			if deletion.Attempts >= maxDeletionAttempts() {
+				updates["status"] = DeletionDead
+				log.Printf("💀 Penghapusan file %s gagal permanen setelah %d percobaan: %v", deletion.PublicID, deletion.Attempts, err)
+			} else {
+				updates["next_attempt_at"] = time.Now().Add(deletionBackoff(deletion.Attempts))
+			}
+		} else {
+			updates["status"] = DeletionDone
+			updates["last_error"] = ""
+			done++
+		}
+
+		if err := database.DB.Model(&deletion).Updates(updates).Error; err != nil {
+			log.Printf("⚠️ Gagal memperbarui outbox %s: %v", deletion.ID, err)
+		}
+	}
+
+	return done, nil
+}
+
+// RetryStorageDeletion mengembalikan item (biasanya yang sudah dead) ke
+// antrean dengan hitungan percobaan dari nol.
+func RetryStorageDeletion(id string) (StorageDeletion, error) {
+	var deletion StorageDeletion
+	if err := database.DB.First(&deletion, "id = ?", id).Error; err != nil {
+		return deletion, err
+	}
+
+	if deletion.Status == DeletionDone {
+		return deletion, fmt.Errorf("file sudah berhasil dihapus")
+	}
+
+	deletion.Status = DeletionPending
+	deletion.Attempts = 0
+	deletion.NextAttemptAt = time.Now()
+
+	err := database.DB.Model(&deletion).Updates(map[string]interface{}{
+		"status":          deletion.Status,
+		"attempts":        deletion.Attempts,
+		"next_attempt_at": deletion.NextAttemptAt,
+	}).Error
+
+	return deletion, err
+}
+
+// StartStorageDeletionWorker menjalankan ProcessStorageDeletions secara berkala.
+func StartStorageDeletionWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			done, err := ProcessStorageDeletions()
+			if err != nil {
+				log.Printf("⚠️ Gagal memproses outbox penghapusan file: %v", err)
+				continue
+			}
+
+			if done > 0 {
+				log.Printf("🗑️ %d file dihapus dari storage", done)
+			}
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package document_staff
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ======================================================
+// GET STORAGE DELETIONS (OUTBOX) - ADMIN ONLY
+// ======================================================
+func GetStorageDeletions(c *gin.Context) {
+	status := c.DefaultQuery("status", DeletionDead)
+
+	pageInt, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || pageInt < 1 {
+		pageInt = 1
+	}
+
+	limitInt, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limitInt < 1 {
+		limitInt = 20
+	}
+
+	if limitInt > 100 {
+		limitInt = 100
+	}
+
+	query := database.DB.Model(&StorageDeletion{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deletions []StorageDeletion
+	if err := query.
+		Order("updated_at DESC").
+		Limit(limitInt).
+		Offset((pageInt - 1) * limitInt).
+		Find(&deletions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil antrean penghapusan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil antrean penghapusan file",
+		"data": gin.H{
+			"deletions": deletions,
+			"pagination": gin.H{
+				"current_page": pageInt,
+				"per_page":     limitInt,
+				"total_items":  total,
+				"total_pages":  int(math.Ceil(float64(total) / float64(limitInt))),
+			},
+		},
+	})
+}
+
+// ======================================================
+// RETRY STORAGE DELETION - ADMIN ONLY
+// ======================================================
+func RetryStorageDeletionAdmin(c *gin.Context) {
+	deletion, err := RetryStorageDeletion(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Gagal mengulang penghapusan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Penghapusan file dijadwalkan ulang",
+		"deletion": deletion,
+	})
+}
@@ -0,0 +1,139 @@
+package document_type
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DocumentType adalah jenis dokumen baku yang dikelola admin, misalnya KTP,
+// KK, atau SK. AllowedExtensions kosong berarti semua file yang lolos
+// pemeriksaan upload diterima, dan MaxSize 0 berarti memakai batas upload
+// umum. SubjectPatterns adalah regex (tanpa membedakan huruf besar/kecil)
+// untuk memetakan subject dokumen lama ke jenis ini.
+type DocumentType struct {
+	ID                string    `gorm:"type:char(36);primaryKey" json:"id"`
+	Code              string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Name              string    `gorm:"type:varchar(150);not null" json:"name"`
+	AllowedExtensions []string  `gorm:"type:text;serializer:json" json:"allowed_extensions"`
+	MaxSize           int64     `json:"max_size"`
+	UniquePerEmployee bool      `json:"unique_per_employee"`
+	SubjectPatterns   []string  `gorm:"type:text;serializer:json" json:"subject_patterns"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (t *DocumentType) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	return
+}
+
+// Find mengambil jenis dokumen berdasarkan id.
+func Find(id string) (DocumentType, error) {
+	var docType DocumentType
+	err := database.DB.First(&docType, "id = ?", id).Error
+	return docType, err
+}
+
+// AllowsFile memeriksa ekstensi nama file terhadap AllowedExtensions.
+func (t DocumentType) AllowsFile(fileName string) bool {
+	if len(t.AllowedExtensions) == 0 {
+		return true
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+	for _, allowed := range t.AllowedExtensions {
+		if ext == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeExtensions menyeragamkan ekstensi menjadi huruf kecil tanpa
+// titik dan membuang duplikat, sehingga ".PDF" dan "pdf" dianggap sama.
+func normalizeExtensions(extensions []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, ext := range extensions {
+		ext = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if ext == "" || seen[ext] {
+			continue
+		}
+		seen[ext] = true
+		normalized = append(normalized, ext)
+	}
+
+	return normalized
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pola %q tidak valid: %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// SubjectMatcher mencocokkan subject bebas dengan pola semua jenis dokumen.
+type SubjectMatcher struct {
+	types    []DocumentType
+	patterns [][]*regexp.Regexp
+}
+
+// NewSubjectMatcher memuat semua jenis dokumen beserta polanya.
+func NewSubjectMatcher() (*SubjectMatcher, error) {
+	var types []DocumentType
+	if err := database.DB.Order("code ASC").Find(&types).Error; err != nil {
+		return nil, err
+	}
+
+	matcher := &SubjectMatcher{}
+	for _, docType := range types {
+		patterns, err := compilePatterns(docType.SubjectPatterns)
+		if err != nil {
+			return nil, fmt.Errorf("jenis dokumen %s: %v", docType.Code, err)
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+
+		matcher.types = append(matcher.types, docType)
+		matcher.patterns = append(matcher.patterns, patterns)
+	}
+
+	return matcher, nil
+}
+
+// Match mengembalikan semua jenis dokumen yang polanya cocok dengan subject.
+// Lebih dari satu hasil berarti subject ambigu.
+func (m *SubjectMatcher) Match(subject string) []DocumentType {
+	subject = strings.TrimSpace(subject)
+
+	var matches []DocumentType
+	for i, patterns := range m.patterns {
+		for _, pattern := range patterns {
+			if pattern.MatchString(subject) {
+				matches = append(matches, m.types[i])
+				break
+			}
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,245 @@
+package document_type
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var codePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type DocumentTypeRequest struct {
+	Code              string   `json:"code" form:"code" binding:"required"`
+	Name              string   `json:"name" form:"name" binding:"required"`
+	AllowedExtensions []string `json:"allowed_extensions" form:"allowed_extensions"`
+	MaxSize           int64    `json:"max_size" form:"max_size"`
+	UniquePerEmployee bool     `json:"unique_per_employee" form:"unique_per_employee"`
+	SubjectPatterns   []string `json:"subject_patterns" form:"subject_patterns"`
+}
+
+// ======================================================
+// GET ALL DOCUMENT TYPES - FOR ALL ROLES
+// ======================================================
+func GetDocumentTypes(c *gin.Context) {
+	var types []DocumentType
+	if err := database.DB.Order("name ASC").Find(&types).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil jenis dokumen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Berhasil mengambil jenis dokumen",
+		"document_types": types,
+	})
+}
+
+// ======================================================
+// GET DOCUMENT TYPE BY ID - FOR ALL ROLES
+// ======================================================
+func GetDocumentType(c *gin.Context) {
+	docType, ok := findDocumentType(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Berhasil mengambil jenis dokumen",
+		"document_type": docType,
+	})
+}
+
+// ======================================================
+// CREATE DOCUMENT TYPE - ADMIN ONLY
+// ======================================================
+func CreateDocumentType(c *gin.Context) {
+	var docType DocumentType
+	if !bindDocumentType(c, &docType) {
+		return
+	}
+
+	if err := database.DB.Create(&docType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan jenis dokumen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Jenis dokumen berhasil dibuat",
+		"document_type": docType,
+	})
+}
+
+// ======================================================
+// UPDATE DOCUMENT TYPE - ADMIN ONLY
+// ======================================================
+// Aturan ekstensi, ukuran, dan keunikan berlaku untuk upload berikutnya;
+// dokumen yang sudah ada tidak diperiksa ulang.
+func UpdateDocumentType(c *gin.Context) {
+	docType, ok := findDocumentType(c)
+	if !ok {
+		return
+	}
+
+	if !bindDocumentType(c, &docType) {
+		return
+	}
+
+	if err := database.DB.Save(&docType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan jenis dokumen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Jenis dokumen berhasil diperbarui",
+		"document_type": docType,
+	})
+}
+
+// ======================================================
+// DELETE DOCUMENT TYPE - ADMIN ONLY
+// ======================================================
+// Jenis yang masih dipakai dokumen aktif tidak bisa dihapus. Dokumen di
+// trash yang memakai jenis ini dilepas jenisnya.
+func DeleteDocumentType(c *gin.Context) {
+	docType, ok := findDocumentType(c)
+	if !ok {
+		return
+	}
+
+	// Baris jenis dikunci lebih dulu agar upload yang sedang menyimpan
+	// dokumen berjenis ini (lihat claimDocumentType) selesai sebelum
+	// pemakaiannya dihitung, dan upload berikutnya menunggu penghapusan.
+	tx := database.DB.Begin()
+
+	var locked DocumentType
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", docType.ID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Jenis dokumen tidak ditemukan"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengunci jenis dokumen: " + err.Error()})
+		return
+	}
+
+	var used int64
+	if err := tx.Table("document_staffs").
+		Where("document_type_id = ? AND deleted_at IS NULL", docType.ID).
+		Count(&used).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa pemakaian jenis dokumen: " + err.Error()})
+		return
+	}
+
+	if used > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Jenis dokumen masih dipakai oleh %d dokumen", used)})
+		return
+	}
+
+	if err := tx.Table("document_staffs").
+		Where("document_type_id = ?", docType.ID).
+		Update("document_type_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal melepas jenis dari dokumen di trash: " + err.Error()})
+		return
+	}
+
+	if err := tx.Delete(&docType).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus jenis dokumen: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus jenis dokumen: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Jenis dokumen berhasil dihapus",
+		"data": gin.H{
+			"id":   docType.ID,
+			"code": docType.Code,
+			"name": docType.Name,
+		},
+	})
+}
+
+func findDocumentType(c *gin.Context) (DocumentType, bool) {
+	docType, err := Find(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Jenis dokumen tidak ditemukan"})
+		return docType, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil jenis dokumen: " + err.Error()})
+		return docType, false
+	}
+
+	return docType, true
+}
+
+// bindDocumentType memvalidasi request lalu menyalinnya ke docType. Jika
+// gagal, response error sudah dikirim.
+func bindDocumentType(c *gin.Context, docType *DocumentType) bool {
+	var req DocumentTypeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return false
+	}
+
+	code := strings.ToLower(strings.TrimSpace(req.Code))
+	if !codePattern.MatchString(code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode hanya boleh berisi huruf kecil, angka, - dan _"})
+		return false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama jenis dokumen wajib diisi"})
+		return false
+	}
+
+	if req.MaxSize < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ukuran maksimal tidak boleh negatif"})
+		return false
+	}
+
+	patterns := []string{}
+	for _, pattern := range req.SubjectPatterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	if _, err := compilePatterns(patterns); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	var count int64
+	database.DB.Model(&DocumentType{}).
+		Where("code = ? AND id <> ?", code, docType.ID).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode jenis dokumen sudah digunakan"})
+		return false
+	}
+
+	docType.Code = code
+	docType.Name = name
+	docType.AllowedExtensions = normalizeExtensions(req.AllowedExtensions)
+	docType.MaxSize = req.MaxSize
+	docType.UniquePerEmployee = req.UniquePerEmployee
+	docType.SubjectPatterns = patterns
+
+	return true
+}
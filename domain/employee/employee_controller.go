@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"BackendKantorDinsos/domain/storage_quota"
 	"BackendKantorDinsos/infrastructure/database"
 
 	"github.com/gin-gonic/gin"
@@ -235,9 +236,18 @@ func GetMe(c *gin.Context) {
 		UpdatedAt: employee.UpdatedAt,
 	}
 
+	usage, err := storage_quota.GetUsage(employee.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Gagal mengambil pemakaian storage: " + err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Berhasil mengambil profile",
 		"profile": response,
+		"storage": usage,
 	})
 }
 
@@ -0,0 +1,216 @@
+package storage_quota
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StorageQuota membatasi pemakaian storage pegawai. Baris dengan employee_id
+// kosong adalah default global, baris lain menimpa default untuk satu
+// pegawai. Nilai 0 berarti tidak dibatasi.
+type StorageQuota struct {
+	ID           string    `gorm:"type:char(36);primaryKey" json:"id"`
+	EmployeeID   string    `gorm:"type:varchar(36);uniqueIndex" json:"employee_id"`
+	MaxBytes     int64     `json:"max_bytes"`
+	MaxDocuments int64     `json:"max_documents"`
+	UpdatedBy    string    `gorm:"type:char(36)" json:"updated_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (q *StorageQuota) BeforeCreate(tx *gorm.DB) (err error) {
+	if q.ID == "" {
+		q.ID = uuid.NewString()
+	}
+	return
+}
+
+// Usage adalah pemakaian storage seorang pegawai. File di trash dan versi
+// lama ikut dihitung karena masih menempati storage.
+type Usage struct {
+	EmployeeID     string `json:"employee_id"`
+	EmployeeName   string `json:"employee_name,omitempty"`
+	DocumentCount  int64  `json:"document_count"`
+	TrashCount     int64  `json:"trash_count"`
+	VersionCount   int64  `json:"version_count"`
+	TotalBytes     int64  `json:"total_bytes"`
+	MaxBytes       int64  `json:"max_bytes"`
+	MaxDocuments   int64  `json:"max_documents"`
+	RemainingBytes *int64 `json:"remaining_bytes"`
+}
+
+// ExceededError dikembalikan Check jika upload melewati kuota.
+type ExceededError struct {
+	Usage   Usage
+	message string
+}
+
+func (e *ExceededError) Error() string {
+	return e.message
+}
+
+// usageQuery menjumlahkan dokumen (termasuk trash) dan versinya per pegawai.
+// Tabel diakses langsung agar paket ini tidak bergantung pada paket
+// document_staff.
+func usageQuery() *gorm.DB {
+	versions := database.DB.Table("document_versions").
+		Select("document_id, COUNT(*) AS version_count, COALESCE(SUM(size), 0) AS version_bytes").
+		Group("document_id")
+
+	return database.DB.Table("document_staffs AS d").
+		Select(`d.employee_id,
+				employees.name AS employee_name,
+				SUM(CASE WHEN d.deleted_at IS NULL THEN 1 ELSE 0 END) AS document_count,
+				SUM(CASE WHEN d.deleted_at IS NULL THEN 0 ELSE 1 END) AS trash_count,
+				COALESCE(SUM(v.version_count), 0) AS version_count,
+				COALESCE(SUM(d.size), 0) + COALESCE(SUM(v.version_bytes), 0) AS total_bytes`).
+		Joins("LEFT JOIN (?) AS v ON v.document_id = d.id", versions).
+		Joins("LEFT JOIN employees ON employees.id = d.employee_id").
+		Where("d.employee_id IS NOT NULL AND d.employee_id <> ''").
+		Group("d.employee_id, employees.name")
+}
+
+// EffectiveQuota mengembalikan kuota khusus pegawai jika ada, jika tidak
+// default global. Tanpa keduanya pemakaian tidak dibatasi.
+func EffectiveQuota(employeeID string) (StorageQuota, error) {
+	var quotas []StorageQuota
+	if err := database.DB.
+		Where("employee_id IN ?", []string{employeeID, ""}).
+		Find(&quotas).Error; err != nil {
+		return StorageQuota{}, err
+	}
+
+	var effective StorageQuota
+	for _, quota := range quotas {
+		if quota.EmployeeID == employeeID {
+			return quota, nil
+		}
+		effective = quota
+	}
+
+	return effective, nil
+}
+
+// GetUsage menghitung pemakaian storage dan kuota yang berlaku untuk pegawai.
+func GetUsage(employeeID string) (Usage, error) {
+	var rows []Usage
+	if err := usageQuery().Where("d.employee_id = ?", employeeID).Scan(&rows).Error; err != nil {
+		return Usage{}, err
+	}
+
+	usage := Usage{EmployeeID: employeeID}
+	if len(rows) > 0 {
+		usage = rows[0]
+	}
+
+	quota, err := EffectiveQuota(employeeID)
+	if err != nil {
+		return usage, err
+	}
+
+	applyQuota(&usage, quota)
+	return usage, nil
+}
+
+func applyQuota(usage *Usage, quota StorageQuota) {
+	usage.MaxBytes = quota.MaxBytes
+	usage.MaxDocuments = quota.MaxDocuments
+	usage.RemainingBytes = nil
+
+	if quota.MaxBytes > 0 {
+		remaining := quota.MaxBytes - usage.TotalBytes
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.RemainingBytes = &remaining
+	}
+}
+
+// Check memastikan pegawai masih boleh menambah incomingBytes dan
+// newDocuments dokumen. Mengembalikan *ExceededError jika kuota terlampaui.
+func Check(employeeID string, incomingBytes, newDocuments int64) error {
+	usage, err := GetUsage(employeeID)
+	if err != nil {
+		return err
+	}
+
+	return usage.Allow(incomingBytes, newDocuments)
+}
+
+// Allow memeriksa tambahan pemakaian terhadap kuota tanpa membaca ulang
+// database. Dipakai juga untuk membagi sisa kuota di antara beberapa file
+// dalam satu request.
+func (u *Usage) Allow(incomingBytes, newDocuments int64) error {
+	if u.MaxBytes > 0 && u.TotalBytes+incomingBytes > u.MaxBytes {
+		return &ExceededError{
+			Usage: *u,
+			message: fmt.Sprintf("Kuota penyimpanan terlampaui: terpakai %s dari %s",
+				formatBytes(u.TotalBytes), formatBytes(u.MaxBytes)),
+		}
+	}
+
+	if u.MaxDocuments > 0 && newDocuments > 0 && u.DocumentCount+newDocuments > u.MaxDocuments {
+		return &ExceededError{
+			Usage:   *u,
+			message: fmt.Sprintf("Batas jumlah dokumen terlampaui: maksimal %d dokumen", u.MaxDocuments),
+		}
+	}
+
+	return nil
+}
+
+// IsExceeded bernilai true jika err berasal dari kuota yang terlampaui.
+func IsExceeded(err error) bool {
+	var exceeded *ExceededError
+	return errors.As(err, &exceeded)
+}
+
+// TopConsumers mengembalikan pegawai dengan pemakaian storage terbesar.
+func TopConsumers(limit int) ([]Usage, error) {
+	var rows []Usage
+	if err := usageQuery().
+		Order("total_bytes DESC").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var quotas []StorageQuota
+	if err := database.DB.Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+
+	byEmployee := map[string]StorageQuota{}
+	for _, quota := range quotas {
+		byEmployee[quota.EmployeeID] = quota
+	}
+
+	for i := range rows {
+		quota, ok := byEmployee[rows[i].EmployeeID]
+		if !ok {
+			quota = byEmployee[""]
+		}
+		applyQuota(&rows[i], quota)
+	}
+
+	return rows, nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
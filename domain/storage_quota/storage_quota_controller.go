@@ -0,0 +1,134 @@
+package storage_quota
+
+import (
+	"net/http"
+	"strconv"
+
+	"BackendKantorDinsos/infrastructure/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaRequest struct {
+	MaxBytes     int64 `json:"max_bytes" form:"max_bytes"`
+	MaxDocuments int64 `json:"max_documents" form:"max_documents"`
+}
+
+// ======================================================
+// GET STORAGE REPORT (TOP CONSUMERS) - ADMIN ONLY
+// ======================================================
+func GetStorageReport(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	if limit > 100 {
+		limit = 100
+	}
+
+	consumers, err := TopConsumers(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil laporan storage: " + err.Error()})
+		return
+	}
+
+	var defaultQuota StorageQuota
+	database.DB.Where("employee_id = ?", "").Limit(1).Find(&defaultQuota)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil laporan storage",
+		"data": gin.H{
+			"default_quota": defaultQuota,
+			"top_consumers": consumers,
+		},
+	})
+}
+
+// ======================================================
+// GET EMPLOYEE USAGE - ADMIN ONLY
+// ======================================================
+func GetEmployeeUsage(c *gin.Context) {
+	usage, err := GetUsage(c.Param("employeeId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil pemakaian storage: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil mengambil pemakaian storage",
+		"usage":   usage,
+	})
+}
+
+// ======================================================
+// SET DEFAULT QUOTA - ADMIN ONLY
+// ======================================================
+func SetDefaultQuota(c *gin.Context) {
+	saveQuota(c, "")
+}
+
+// ======================================================
+// SET EMPLOYEE QUOTA OVERRIDE - ADMIN ONLY
+// ======================================================
+func SetEmployeeQuota(c *gin.Context) {
+	employeeID := c.Param("employeeId")
+
+	var count int64
+	database.DB.Table("employees").Where("id = ?", employeeID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee tidak ditemukan"})
+		return
+	}
+
+	saveQuota(c, employeeID)
+}
+
+// ======================================================
+// REMOVE EMPLOYEE QUOTA OVERRIDE - ADMIN ONLY
+// ======================================================
+func DeleteEmployeeQuota(c *gin.Context) {
+	result := database.DB.Where("employee_id = ?", c.Param("employeeId")).Delete(&StorageQuota{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus kuota: " + result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kuota khusus tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kuota khusus dihapus, pegawai kembali memakai kuota default"})
+}
+
+func saveQuota(c *gin.Context, employeeID string) {
+	var req QuotaRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Input tidak valid: " + err.Error()})
+		return
+	}
+
+	if req.MaxBytes < 0 || req.MaxDocuments < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kuota tidak boleh negatif"})
+		return
+	}
+
+	var quota StorageQuota
+	database.DB.Where("employee_id = ?", employeeID).Limit(1).Find(&quota)
+
+	quota.EmployeeID = employeeID
+	quota.MaxBytes = req.MaxBytes
+	quota.MaxDocuments = req.MaxDocuments
+	quota.UpdatedBy = c.GetString("employeeID")
+
+	if err := database.DB.Save(&quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kuota: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kuota penyimpanan berhasil disimpan",
+		"quota":   quota,
+	})
+}
@@ -0,0 +1,255 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mode menentukan perilaku upload saat pemindaian antivirus.
+type Mode string
+
+const (
+	// ModeOff mematikan pemindaian.
+	ModeOff Mode = "off"
+	// ModeFailOpen menerima file jika clamd tidak bisa dihubungi.
+	ModeFailOpen Mode = "fail-open"
+	// ModeFailClosed menolak file jika clamd tidak bisa dihubungi.
+	ModeFailClosed Mode = "fail-closed"
+)
+
+// chunkSize adalah ukuran maksimal satu chunk INSTREAM.
+const chunkSize = 64 * 1024
+
+var ErrSizeLimit = errors.New("ukuran file melebihi StreamMaxLength clamd")
+
+// Result adalah hasil pemindaian satu file.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Client berbicara dengan clamd lewat TCP atau unix socket.
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClient(network, address string, timeout time.Duration) *Client {
+	return &Client{network: network, address: address, timeout: timeout}
+}
+
+// NewClientFromEnv membaca konfigurasi:
+//
+//	CLAMAV_MODE     off (default), fail-open, fail-closed
+//	CLAMAV_ADDRESS  tcp://127.0.0.1:3310 (default) atau unix:///run/clamav/clamd.ctl
+//	CLAMAV_TIMEOUT  batas waktu tanpa aktivitas, default 60s
+//
+// StreamMaxLength di clamd.conf sebaiknya tidak lebih kecil dari
+// UPLOAD_MAX_RAW_MB; file yang lebih besar dari batas clamd ditolak dalam
+// mode fail-closed. Client bernilai nil jika mode off.
+func NewClientFromEnv() (*Client, Mode, error) {
+	mode := Mode(strings.ToLower(strings.TrimSpace(os.Getenv("CLAMAV_MODE"))))
+	switch mode {
+	case "", ModeOff:
+		return nil, ModeOff, nil
+	case ModeFailOpen, ModeFailClosed:
+	default:
+		return nil, ModeOff, fmt.Errorf("CLAMAV_MODE tidak dikenal: %s", mode)
+	}
+
+	address := os.Getenv("CLAMAV_ADDRESS")
+	if address == "" {
+		address = "tcp://127.0.0.1:3310"
+	}
+
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok || (network != "tcp" && network != "unix") {
+		return nil, mode, fmt.Errorf("CLAMAV_ADDRESS harus diawali tcp:// atau unix://")
+	}
+
+	timeout := 60 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("CLAMAV_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	return NewClient(network, addr, timeout), mode, nil
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("clamd tidak dapat dihubungi: %v", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	return conn, nil
+}
+
+// Ping memastikan clamd hidup.
+func (c *Client) Ping() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+
+	if reply != "PONG" {
+		return fmt.Errorf("balasan clamd tidak dikenal: %s", reply)
+	}
+
+	return nil
+}
+
+// Scan memindai seluruh isi r dengan perintah INSTREAM.
+func (c *Client) Scan(r io.Reader) (Result, error) {
+	stream, err := c.NewStream()
+	if err != nil {
+		return Result{}, err
+	}
+
+	if _, err := io.Copy(stream, r); err != nil {
+		stream.Close()
+		return Result{}, err
+	}
+
+	return stream.Result()
+}
+
+// Stream adalah sesi INSTREAM yang menerima data lewat Write, sehingga bisa
+// dipasang di io.TeeReader bersamaan dengan upload ke storage.
+//
+// Write tidak pernah mengembalikan error agar kegagalan clamd tidak memutus
+// upload; error disimpan dan dikembalikan oleh Result.
+type Stream struct {
+	conn    net.Conn
+	timeout time.Duration
+	buf     []byte
+	err     error
+}
+
+func (c *Client) NewStream() (*Stream, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("gagal mengirim perintah INSTREAM: %v", err)
+	}
+
+	return &Stream{conn: conn, timeout: c.timeout, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && s.err == nil {
+		take := chunkSize - len(s.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+
+		s.buf = append(s.buf, p[:take]...)
+		p = p[take:]
+
+		if len(s.buf) == chunkSize {
+			s.flush()
+		}
+	}
+
+	return n, nil
+}
+
+func (s *Stream) flush() {
+	if len(s.buf) == 0 || s.err != nil {
+		return
+	}
+
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(s.buf)))
+
+	if _, err := s.conn.Write(size[:]); err != nil {
+		s.err = err
+	} else if _, err := s.conn.Write(s.buf); err != nil {
+		s.err = err
+	}
+
+	s.buf = s.buf[:0]
+}
+
+// Result mengakhiri stream dan membaca putusan clamd.
+func (s *Stream) Result() (Result, error) {
+	defer s.conn.Close()
+
+	s.flush()
+	if s.err == nil {
+		_, s.err = s.conn.Write([]byte{0, 0, 0, 0})
+	}
+
+	// clamd bisa memutus koneksi lebih awal (misalnya batas ukuran), tetapi
+	// balasannya tetap perlu dibaca untuk mengetahui alasannya.
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	reply, err := readReply(s.conn)
+	if err != nil {
+		if s.err != nil {
+			return Result{}, fmt.Errorf("gagal mengirim data ke clamd: %v", s.err)
+		}
+		return Result{}, err
+	}
+
+	return parseReply(reply)
+}
+
+// Close membatalkan stream tanpa menunggu putusan.
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", fmt.Errorf("gagal membaca balasan clamd: %v", err)
+	}
+
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseReply mengartikan balasan seperti:
+//
+//	stream: OK
+//	stream: Win.Test.EICAR_HDB-1 FOUND
+//	INSTREAM size limit exceeded. ERROR
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		return Result{}, ErrSizeLimit
+	default:
+		return Result{}, fmt.Errorf("clamd error: %s", reply)
+	}
+}
@@ -0,0 +1,169 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd menjalankan listener yang meniru protokol zINSTREAM clamd.
+// Data yang mengandung string EICAR dilaporkan FOUND, dan stream yang
+// melewati maxLength dijawab "size limit exceeded" lalu koneksi ditutup
+// tanpa menunggu sisa data, seperti clamd.
+func fakeClamd(t *testing.T, maxLength int) *Client {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, maxLength)
+		}
+	}()
+
+	return NewClient("tcp", listener.Addr().String(), 5*time.Second)
+}
+
+func serveClamd(conn net.Conn, maxLength int) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	command, err := reader.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data []byte
+		for {
+			var size [4]byte
+			if _, err := io.ReadFull(reader, size[:]); err != nil {
+				return
+			}
+
+			n := binary.BigEndian.Uint32(size[:])
+			if n == 0 {
+				break
+			}
+
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(reader, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+
+			if len(data) > maxLength {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+		}
+
+		if bytes.Contains(data, []byte(eicar)) {
+			conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+			return
+		}
+		conn.Write([]byte("stream: OK\x00"))
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func TestPing(t *testing.T) {
+	client := fakeClamd(t, 1<<20)
+
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestScanClean(t *testing.T) {
+	client := fakeClamd(t, 1<<20)
+
+	// Lebih dari satu chunk agar pemecahan INSTREAM ikut teruji.
+	result, err := client.Scan(bytes.NewReader(bytes.Repeat([]byte("dokumen bersih "), 10000)))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if result.Infected {
+		t.Fatalf("file bersih terdeteksi virus: %+v", result)
+	}
+}
+
+func TestScanFound(t *testing.T) {
+	client := fakeClamd(t, 1<<20)
+
+	result, err := client.Scan(strings.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !result.Infected || result.Signature != "Win.Test.EICAR_HDB-1" {
+		t.Fatalf("Scan = %+v, want FOUND Win.Test.EICAR_HDB-1", result)
+	}
+}
+
+func TestStreamFound(t *testing.T) {
+	client := fakeClamd(t, 1<<20)
+
+	stream, err := client.NewStream()
+	if err != nil {
+		t.Fatalf("NewStream: %v", err)
+	}
+
+	// Tanda EICAR terpotong di antara dua Write.
+	stream.Write([]byte(eicar[:20]))
+	stream.Write([]byte(eicar[20:]))
+
+	result, err := stream.Result()
+	if err != nil {
+		t.Fatalf("Result: %v", err)
+	}
+	if !result.Infected {
+		t.Fatalf("Result = %+v, want infected", result)
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	client := fakeClamd(t, 100<<10)
+
+	_, err := client.Scan(bytes.NewReader(make([]byte, 4<<20)))
+	if !errors.Is(err, ErrSizeLimit) {
+		t.Fatalf("Scan = %v, want ErrSizeLimit", err)
+	}
+}
+
+func TestScanConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	client := NewClient("tcp", address, time.Second)
+
+	if _, err := client.NewStream(); err == nil {
+		t.Fatal("NewStream ke port tertutup seharusnya gagal")
+	}
+	if _, err := client.Scan(strings.NewReader("x")); err == nil || errors.Is(err, ErrSizeLimit) {
+		t.Fatalf("Scan = %v, want connection error", err)
+	}
+}
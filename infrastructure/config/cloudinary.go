@@ -5,10 +5,12 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -31,7 +33,40 @@ type CloudinaryErrorResponse struct {
 	} `json:"error"`
 }
 
-func UploadToCloudinary(file io.Reader, fileName, folder, resourceType string) (CloudinaryResponse, error) {
+// CloudinaryDeliveryType adalah tipe delivery asset, diatur lewat env
+// CLOUDINARY_DELIVERY_TYPE: "authenticated" (default), "private" atau
+// "upload". Default "authenticated" agar file hanya bisa diakses lewat URL
+// bertanda tangan; "upload" (publik) hanya dipakai jika diminta secara
+// eksplisit. File lama yang masih bertipe "upload" tetap bisa diunduh dan
+// dihapus lewat fallback di storage, tetapi jalankan migrate-private-delivery
+// sampai selesai agar URL publiknya tidak berlaku lagi dan URL bertanda
+// tangan untuk file tersebut bisa dipakai.
+func CloudinaryDeliveryType() string {
+	switch deliveryType := strings.ToLower(strings.TrimSpace(os.Getenv("CLOUDINARY_DELIVERY_TYPE"))); deliveryType {
+	case "upload", "authenticated", "private":
+		return deliveryType
+	default:
+		return "authenticated"
+	}
+}
+
+// signCloudinaryParams membuat signature SHA-1 dari parameter yang diurutkan
+// sesuai aturan Cloudinary.
+func signCloudinaryParams(params map[string]string, apiSecret string) string {
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key+"="+value)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	h.Write([]byte(strings.Join(keys, "&") + apiSecret))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func UploadToCloudinary(file io.Reader, fileName, folder, resourceType, deliveryType string) (CloudinaryResponse, error) {
 	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
 	apiKey := os.Getenv("CLOUDINARY_API_KEY")
 	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
@@ -54,6 +89,7 @@ func UploadToCloudinary(file io.Reader, fileName, folder, resourceType string) (
 	}
 
 	params = append(params, "timestamp="+timestamp)
+	params = append(params, "type="+deliveryType)
 	params = append(params, "unique_filename=false")
 	params = append(params, "use_filename=true")
 
@@ -65,37 +101,55 @@ func UploadToCloudinary(file io.Reader, fileName, folder, resourceType string) (
 	h.Write([]byte(signatureString))
 	signature := hex.EncodeToString(h.Sum(nil))
 
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
-
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		return CloudinaryResponse{}, fmt.Errorf("failed to create form file: %v", err)
-	}
-	if _, err := io.Copy(part, file); err != nil {
-		return CloudinaryResponse{}, fmt.Errorf("failed to copy file: %v", err)
-	}
-
-	writer.WriteField("api_key", apiKey)
-	writer.WriteField("timestamp", timestamp)
-	writer.WriteField("signature", signature)
-
-	writer.WriteField("use_filename", "true")
-	writer.WriteField("unique_filename", "false")
+	// Body multipart ditulis lewat io.Pipe sehingga file dialirkan langsung
+	// ke Cloudinary tanpa ditampung di memori.
+	bodyReader, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+
+	// Goroutine penulis masih membaca file milik pemanggil, jadi harus
+	// selesai sebelum fungsi ini kembali, termasuk ketika Cloudinary menolak
+	// request sebelum body terkirim semua.
+	done := make(chan struct{})
+	defer func() {
+		bodyReader.Close()
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+
+		writer.WriteField("api_key", apiKey)
+		writer.WriteField("timestamp", timestamp)
+		writer.WriteField("signature", signature)
+		writer.WriteField("type", deliveryType)
+
+		writer.WriteField("use_filename", "true")
+		writer.WriteField("unique_filename", "false")
+
+		if folder != "" {
+			writer.WriteField("folder", folder)
+		}
 
-	if folder != "" {
-		writer.WriteField("folder", folder)
-	}
+		part, err := writer.CreateFormFile("file", fileName)
+		if err != nil {
+			bodyWriter.CloseWithError(fmt.Errorf("failed to create form file: %v", err))
+			return
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			bodyWriter.CloseWithError(fmt.Errorf("failed to copy file: %v", err))
+			return
+		}
 
-	writer.Close()
+		bodyWriter.CloseWithError(writer.Close())
+	}()
 
-	req, err := http.NewRequest("POST", url, &body)
+	req, err := http.NewRequest("POST", url, bodyReader)
 	if err != nil {
 		return CloudinaryResponse{}, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Add("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Minute}
 
 	fmt.Println("📤 Sending file to Cloudinary...")
 
@@ -127,12 +181,12 @@ func UploadToCloudinary(file io.Reader, fileName, folder, resourceType string) (
 	return result, nil
 }
 
-func CloudinaryFileExists(publicID, resourceType string) bool {
+func CloudinaryFileExists(publicID, resourceType, deliveryType string) bool {
 	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
 	apiKey := os.Getenv("CLOUDINARY_API_KEY")
 	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
 
-	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/%s/upload/%s", cloudName, resourceType, publicID)
+	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/resources/%s/%s/%s", cloudName, resourceType, deliveryType, publicID)
 
 	req, _ := http.NewRequest("GET", url, nil)
 	req.SetBasicAuth(apiKey, apiSecret)
@@ -147,6 +201,46 @@ func CloudinaryFileExists(publicID, resourceType string) bool {
 	return resp.StatusCode == 200
 }
 
+// CloudinaryResourceBytes mengambil ukuran asset lewat Admin API.
+func CloudinaryResourceBytes(publicID, resourceType, deliveryType string) (int64, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+
+	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/resources/%s/%s/%s", cloudName, resourceType, deliveryType, publicID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(apiKey, apiSecret)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request to Cloudinary failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ErrCloudinaryNotFound
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("resource lookup failed: %s", string(respBody))
+	}
+
+	var result struct {
+		Bytes int64 `json:"bytes"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return 0, fmt.Errorf("invalid resource response format: %v", err)
+	}
+
+	return result.Bytes, nil
+}
+
 func GenerateUniqueFileName(folder, originalName, resourceType string) string {
 	ext := ""
 	name := originalName
@@ -163,7 +257,7 @@ func GenerateUniqueFileName(folder, originalName, resourceType string) string {
 	publicID := fmt.Sprintf("%s/%s", folder, uniqueName)
 
 	counter := 1
-	for CloudinaryFileExists(publicID, resourceType) {
+	for CloudinaryFileExists(publicID, resourceType, CloudinaryDeliveryType()) {
 		newName := fmt.Sprintf("%s_%d_%s(%d)%s", name, timestamp, randomStr, counter, ext)
 		publicID = fmt.Sprintf("%s/%s", folder, newName)
 		counter++
@@ -172,11 +266,16 @@ func GenerateUniqueFileName(folder, originalName, resourceType string) string {
 	return uniqueName
 }
 
+var ErrCloudinaryNotFound = errors.New("file tidak ditemukan di Cloudinary")
+
 type CloudinaryDeleteResponse struct {
 	Result string `json:"result"`
 }
 
-func DeleteFromCloudinary(publicID string, resourceType string) error {
+// DeleteFromCloudinary menghapus asset. Hasil "not found" dikembalikan
+// sebagai ErrCloudinaryNotFound agar pemanggil bisa mencoba tipe delivery
+// lain.
+func DeleteFromCloudinary(publicID, resourceType, deliveryType string) error {
 	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
 	apiKey := os.Getenv("CLOUDINARY_API_KEY")
 	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
@@ -189,14 +288,16 @@ func DeleteFromCloudinary(publicID string, resourceType string) error {
 
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 
-	signatureString := fmt.Sprintf("public_id=%s&timestamp=%s%s", publicID, timestamp, apiSecret)
-	h := sha1.New()
-	h.Write([]byte(signatureString))
-	signature := hex.EncodeToString(h.Sum(nil))
+	signature := signCloudinaryParams(map[string]string{
+		"public_id": publicID,
+		"timestamp": timestamp,
+		"type":      deliveryType,
+	}, apiSecret)
 
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 	writer.WriteField("public_id", publicID)
+	writer.WriteField("type", deliveryType)
 	writer.WriteField("api_key", apiKey)
 	writer.WriteField("timestamp", timestamp)
 	writer.WriteField("signature", signature)
@@ -235,10 +336,168 @@ func DeleteFromCloudinary(publicID string, resourceType string) error {
 		return fmt.Errorf("invalid delete response format: %v", err)
 	}
 
-	if result.Result == "ok" || result.Result == "not found" {
-		fmt.Printf("✅ Delete success (or file not found): %s\n", publicID)
+	if result.Result == "ok" {
+		fmt.Printf("✅ Delete success: %s\n", publicID)
 		return nil
 	}
 
+	if result.Result == "not found" {
+		return ErrCloudinaryNotFound
+	}
+
 	return fmt.Errorf("delete failed with result: %s", result.Result)
 }
+
+type CloudinaryResource struct {
+	PublicID  string    `json:"public_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CloudinaryListResponse struct {
+	Resources  []CloudinaryResource `json:"resources"`
+	NextCursor string               `json:"next_cursor"`
+}
+
+// ListCloudinaryResources mengambil semua asset di bawah prefix (folder)
+// lewat Admin API, mengikuti next_cursor sampai habis.
+func ListCloudinaryResources(prefix, resourceType, deliveryType string) ([]CloudinaryResource, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return nil, fmt.Errorf("cloudinary credentials tidak lengkap")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resources := []CloudinaryResource{}
+	cursor := ""
+
+	for {
+		query := neturl.Values{}
+		query.Set("prefix", prefix)
+		query.Set("max_results", "500")
+		if cursor != "" {
+			query.Set("next_cursor", cursor)
+		}
+
+		url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/resources/%s/%s?%s", cloudName, resourceType, deliveryType, query.Encode())
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.SetBasicAuth(apiKey, apiSecret)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request to Cloudinary failed: %v", err)
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			var errResp CloudinaryErrorResponse
+			if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+				return nil, fmt.Errorf("cloudinary list error: %s", errResp.Error.Message)
+			}
+			return nil, fmt.Errorf("list failed: %s", string(respBody))
+		}
+
+		var result CloudinaryListResponse
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("invalid list response format: %v", err)
+		}
+
+		resources = append(resources, result.Resources...)
+
+		if result.NextCursor == "" {
+			return resources, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// RenameCloudinaryResource memindahkan asset ke tipe delivery lain dengan
+// public id yang sama, dipakai untuk migrasi file publik lama ke mode privat.
+func RenameCloudinaryResource(publicID, resourceType, fromType, toType string) (CloudinaryResponse, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return CloudinaryResponse{}, fmt.Errorf("cloudinary credentials tidak lengkap")
+	}
+
+	url := fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/%s/rename", cloudName, resourceType)
+
+	params := map[string]string{
+		"from_public_id": publicID,
+		"to_public_id":   publicID,
+		"type":           fromType,
+		"to_type":        toType,
+		"timestamp":      strconv.FormatInt(time.Now().Unix(), 10),
+	}
+
+	form := neturl.Values{}
+	for key, value := range params {
+		form.Set(key, value)
+	}
+	form.Set("api_key", apiKey)
+	form.Set("signature", signCloudinaryParams(params, apiSecret))
+
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	resp, err := client.PostForm(url, form)
+	if err != nil {
+		return CloudinaryResponse{}, fmt.Errorf("request to Cloudinary rename failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		var errResp CloudinaryErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			return CloudinaryResponse{}, fmt.Errorf("cloudinary rename error: %s", errResp.Error.Message)
+		}
+		return CloudinaryResponse{}, fmt.Errorf("rename failed: %s", string(respBody))
+	}
+
+	var result CloudinaryResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return CloudinaryResponse{}, fmt.Errorf("invalid rename response format: %v", err)
+	}
+
+	return result, nil
+}
+
+// CloudinaryPrivateDownloadURL membuat URL download bertanda tangan yang
+// berlaku sampai expiresAt. URL ini bisa dipakai untuk asset bertipe
+// "authenticated" maupun "private".
+func CloudinaryPrivateDownloadURL(publicID, resourceType, deliveryType string, expiresAt time.Time) (string, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return "", fmt.Errorf("cloudinary credentials tidak lengkap")
+	}
+
+	params := map[string]string{
+		"public_id":  publicID,
+		"type":       deliveryType,
+		"expires_at": strconv.FormatInt(expiresAt.Unix(), 10),
+		"timestamp":  strconv.FormatInt(time.Now().Unix(), 10),
+	}
+
+	query := neturl.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	query.Set("api_key", apiKey)
+	query.Set("signature", signCloudinaryParams(params, apiSecret))
+
+	return fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/%s/download?%s", cloudName, resourceType, query.Encode()), nil
+}
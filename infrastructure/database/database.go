@@ -37,3 +37,9 @@ func ConnectDatabase() {
 
 	log.Println("✅ Database Railway MySQL terkoneksi")
 }
+
+// Migrate menjalankan AutoMigrate. Query migrasi ditandai sebagai terpercaya
+// agar ALTER TABLE dan constraint ON DELETE tidak diblokir query protector.
+func Migrate(models ...interface{}) error {
+	return DB.WithContext(trustedContext()).AutoMigrate(models...)
+}
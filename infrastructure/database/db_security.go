@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"log"
 	"regexp"
@@ -33,13 +34,25 @@ func isQueryDangerous(sql string) bool {
 	return false
 }
 
+// penanda context untuk migrasi skema (AutoMigrate memakai ALTER TABLE)
+type trustedMigrationKey struct{}
+
+func trustedContext() context.Context {
+	return context.WithValue(context.Background(), trustedMigrationKey{}, true)
+}
+
+func isTrusted(db *gorm.DB) bool {
+	trusted, _ := db.Statement.Context.Value(trustedMigrationKey{}).(bool)
+	return trusted
+}
+
 // callback sebelum query dieksekusi
 func registerQueryProtector(db *gorm.DB) {
 	callback := db.Callback()
 
 	// semua Raw SQL dan Exec SQL akan lewat sini
 	callback.Raw().Before("gorm:raw").Register("security:check_raw_sql", func(db *gorm.DB) {
-		if db.Statement.SQL.String() != "" {
+		if db.Statement.SQL.String() != "" && !isTrusted(db) {
 			sql := db.Statement.SQL.String()
 
 			if isQueryDangerous(sql) {
@@ -0,0 +1,267 @@
+package filecheck
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrUnsupported = errors.New("format file tidak didukung")
+	ErrMismatch    = errors.New("isi file tidak sesuai dengan ekstensi")
+	ErrMacro       = errors.New("dokumen Office yang mengandung macro tidak diizinkan")
+	ErrJavaScript  = errors.New("PDF yang mengandung JavaScript tidak diizinkan")
+	// ErrUnverifiable dikembalikan untuk PDF yang object stream-nya tidak
+	// dapat dibaca (filter tidak didukung, terenkripsi, rusak atau terlalu
+	// besar), sehingga tidak bisa dipastikan bebas JavaScript.
+	ErrUnverifiable = errors.New("isi PDF tidak dapat diperiksa")
+)
+
+const sniffLen = 512
+
+type family int
+
+const (
+	familyJPEG family = iota
+	familyPNG
+	familyGIF
+	familyWEBP
+	familyPDF
+	familyOOXML
+	familyOLE
+)
+
+type fileType struct {
+	mimeType string
+	family   family
+	// marker yang wajib muncul di isi file (misalnya nama part OOXML/OLE)
+	// untuk membedakan docx, xlsx dan pptx yang sama-sama berbentuk zip.
+	markers [][]byte
+}
+
+var fileTypes = map[string]fileType{
+	".jpg":  {mimeType: "image/jpeg", family: familyJPEG},
+	".jpeg": {mimeType: "image/jpeg", family: familyJPEG},
+	".png":  {mimeType: "image/png", family: familyPNG},
+	".gif":  {mimeType: "image/gif", family: familyGIF},
+	".webp": {mimeType: "image/webp", family: familyWEBP},
+	".pdf":  {mimeType: "application/pdf", family: familyPDF},
+	".docx": {
+		mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		family:   familyOOXML,
+		markers:  [][]byte{[]byte("word/")},
+	},
+	".xlsx": {
+		mimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		family:   familyOOXML,
+		markers:  [][]byte{[]byte("xl/")},
+	},
+	".pptx": {
+		mimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		family:   familyOOXML,
+		markers:  [][]byte{[]byte("ppt/")},
+	},
+	".doc": {
+		mimeType: "application/msword",
+		family:   familyOLE,
+		markers:  [][]byte{utf16("WordDocument")},
+	},
+	".xls": {
+		mimeType: "application/vnd.ms-excel",
+		family:   familyOLE,
+		markers:  [][]byte{utf16("Workbook"), utf16("Book")},
+	},
+	".ppt": {
+		mimeType: "application/vnd.ms-powerpoint",
+		family:   familyOLE,
+		markers:  [][]byte{utf16("PowerPoint Document")},
+	},
+}
+
+// Penanda konten aktif yang ditolak. Nama part OOXML dan nama stream OLE
+// tersimpan tanpa kompresi sehingga dapat dicari langsung di stream.
+// JavaScript PDF diperiksa oleh pdfScanner.
+var (
+	ooxmlMacroMarkers = [][]byte{[]byte("vbaProject.bin"), []byte("vbaData.xml")}
+	oleMacroMarkers   = [][]byte{utf16("_VBA_PROJECT"), utf16("Macros")}
+)
+
+// MimeTypeByExtension mengembalikan MIME type yang diharapkan untuk ekstensi
+// file, atau false jika ekstensi tidak didukung.
+func MimeTypeByExtension(fileName string) (string, bool) {
+	ft, ok := fileTypes[strings.ToLower(filepath.Ext(fileName))]
+	return ft.mimeType, ok
+}
+
+// Inspector membaca isi file yang sedang di-stream, memastikan jenis file
+// sesuai dengan byte awalnya (magic bytes) dan memeriksa konten aktif
+// (macro Office, JavaScript PDF) selama data mengalir.
+type Inspector struct {
+	r        *bufio.Reader
+	fileType fileType
+	required *scanner
+	rejected *scanner
+	reject   error
+	pdf      *pdfScanner
+	done     bool
+}
+
+// NewInspector membaca byte awal file dan menolak file yang ekstensinya tidak
+// didukung atau isinya tidak sesuai dengan ekstensi.
+func NewInspector(r io.Reader, fileName string) (*Inspector, error) {
+	ft, ok := fileTypes[strings.ToLower(filepath.Ext(fileName))]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	br := bufio.NewReaderSize(r, 4096)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if detectFamily(head) != ft.family {
+		return nil, ErrMismatch
+	}
+
+	inspector := &Inspector{r: br, fileType: ft}
+
+	switch ft.family {
+	case familyOOXML:
+		inspector.rejected, inspector.reject = newScanner(ooxmlMacroMarkers), ErrMacro
+	case familyOLE:
+		inspector.rejected, inspector.reject = newScanner(oleMacroMarkers), ErrMacro
+	case familyPDF:
+		inspector.pdf = newPDFScanner()
+	}
+
+	if len(ft.markers) > 0 {
+		inspector.required = newScanner(ft.markers)
+	}
+
+	return inspector, nil
+}
+
+func (i *Inspector) Read(p []byte) (int, error) {
+	n, err := i.r.Read(p)
+	if n > 0 {
+		if i.required != nil {
+			i.required.write(p[:n])
+		}
+		if i.rejected != nil {
+			i.rejected.write(p[:n])
+		}
+		if i.pdf != nil {
+			i.pdf.write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		i.done = true
+	}
+	return n, err
+}
+
+// MimeType adalah MIME type file hasil pemeriksaan isi.
+func (i *Inspector) MimeType() string {
+	return i.fileType.mimeType
+}
+
+// Verdict dipanggil setelah seluruh file dibaca dan mengembalikan error jika
+// file mengandung konten yang ditolak atau strukturnya tidak sesuai.
+func (i *Inspector) Verdict() error {
+	if !i.done {
+		if _, err := io.Copy(io.Discard, i); err != nil {
+			return err
+		}
+	}
+
+	if i.rejected != nil && i.rejected.found {
+		return i.reject
+	}
+
+	if i.pdf != nil {
+		i.pdf.finish()
+		if i.pdf.found {
+			return ErrJavaScript
+		}
+		if i.pdf.err != nil {
+			return i.pdf.err
+		}
+	}
+
+	if i.required != nil && !i.required.found {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func detectFamily(head []byte) family {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return familyJPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return familyPNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return familyGIF
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return familyWEBP
+	case bytes.HasPrefix(bytes.TrimLeft(head, "\xEF\xBB\xBF \t\r\n"), []byte("%PDF-")):
+		return familyPDF
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return familyOOXML
+	case bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return familyOLE
+	default:
+		return -1
+	}
+}
+
+// scanner mencari beberapa marker di stream yang datang per potongan, dengan
+// menyimpan ekor potongan sebelumnya agar marker yang terbelah tetap ketemu.
+type scanner struct {
+	markers [][]byte
+	tail    []byte
+	keep    int
+	found   bool
+}
+
+func newScanner(markers [][]byte) *scanner {
+	keep := 0
+	for _, m := range markers {
+		if len(m) > keep {
+			keep = len(m)
+		}
+	}
+	return &scanner{markers: markers, keep: keep - 1}
+}
+
+func (s *scanner) write(p []byte) {
+	if s.found {
+		return
+	}
+
+	window := append(s.tail, p...)
+	for _, m := range s.markers {
+		if bytes.Contains(window, m) {
+			s.found = true
+			return
+		}
+	}
+
+	if len(window) > s.keep {
+		window = window[len(window)-s.keep:]
+	}
+	s.tail = append(s.tail[:0], window...)
+}
+
+func utf16(s string) []byte {
+	b := make([]byte, 0, len(s)*2)
+	for _, r := range s {
+		b = append(b, byte(r), 0)
+	}
+	return b
+}
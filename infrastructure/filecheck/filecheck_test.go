@@ -0,0 +1,147 @@
+package filecheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// inspect menjalankan Inspector seperti saat upload. Data dibaca satu byte
+// per Read agar marker yang terbelah di antara potongan ikut teruji.
+func inspect(t *testing.T, fileName string, data []byte) (string, error) {
+	t.Helper()
+
+	inspector, err := NewInspector(iotest.OneByteReader(bytes.NewReader(data)), fileName)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(io.Discard, inspector); err != nil {
+		t.Fatalf("membaca %s: %v", fileName, err)
+	}
+
+	return inspector.MimeType(), inspector.Verdict()
+}
+
+func zipWith(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("<xml/>"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func pdf(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i, object := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+// objStm membuat object stream berisi content yang dikompresi FlateDecode.
+func objStm(t *testing.T, content string, level int) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+
+	return fmt.Sprintf("<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode /Length %d >>\nstream\r\n%s\nendstream", buf.Len(), buf.Bytes())
+}
+
+const catalog = "<< /Type /Catalog /Pages 2 0 R >>"
+
+func TestUnsupportedExtension(t *testing.T) {
+	if _, err := inspect(t, "setup.exe", []byte("MZ\x90\x00")); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestMagicMismatch(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if _, err := inspect(t, "scan.pdf", png); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("PNG sebagai .pdf: err = %v, want ErrMismatch", err)
+	}
+
+	if _, err := inspect(t, "foto.jpg", pdf(catalog)); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("PDF sebagai .jpg: err = %v, want ErrMismatch", err)
+	}
+
+	// zip yang bukan dokumen Word
+	if _, err := inspect(t, "laporan.docx", zipWith(t, "[Content_Types].xml", "xl/workbook.xml")); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("xlsx sebagai .docx: err = %v, want ErrMismatch", err)
+	}
+}
+
+func TestOfficeDocument(t *testing.T) {
+	mimeType, err := inspect(t, "laporan.docx", zipWith(t, "[Content_Types].xml", "word/document.xml"))
+	if err != nil {
+		t.Fatalf("docx bersih ditolak: %v", err)
+	}
+	if mimeType != "application/vnd.openxmlformats-officedocument.wordprocessingml.document" {
+		t.Fatalf("MimeType = %s", mimeType)
+	}
+}
+
+func TestMacroDocumentAsDocx(t *testing.T) {
+	docm := zipWith(t, "[Content_Types].xml", "word/document.xml", "word/vbaProject.bin")
+	if _, err := inspect(t, "laporan.docx", docm); !errors.Is(err, ErrMacro) {
+		t.Fatalf("docm sebagai .docx: err = %v, want ErrMacro", err)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"bersih", pdf(catalog, "<< /Type /Pages /Kids [] /Count 0 /JSONData (x) >>"), nil},
+		{"JavaScript polos", pdf(catalog, "<< /S /JavaScript /JS (app.alert(1)) >>"), ErrJavaScript},
+		{"OpenAction tanpa spasi", pdf("<</Type/Catalog/OpenAction<</S/JavaScript/JS(app.alert(1))>>>>"), ErrJavaScript},
+		{"nama di-escape", pdf(catalog, "<< /S /J#61vaScript /#4A#53 (app.alert(1)) >>"), ErrJavaScript},
+		{"escape huruf kecil", pdf(catalog, "<< /AA << /O << /S /Java#53cript >> >> >>"), ErrJavaScript},
+		{"object stream", pdf(catalog, objStm(t, "3 0 << /S /JavaScript /JS (app.alert(1)) >>", zlib.BestCompression)), ErrJavaScript},
+		{"object stream bersih", pdf(catalog, objStm(t, "3 0 << /Type /Page /Parent 2 0 R >>", zlib.BestCompression)), nil},
+		// Tanpa kompresi, teks "endstream" di dalam data terkompresi terlihat
+		// apa adanya dan tidak boleh mengakhiri pemeriksaan lebih awal.
+		{"endstream palsu di object stream", pdf(catalog, objStm(t, "3 0 << /T (a endstream b) /S /J#61vaScript >>", zlib.NoCompression)), ErrJavaScript},
+		{"filter object stream tidak didukung", pdf(catalog, "<< /Type /ObjStm /N 1 /First 4 /Filter /ASCIIHexDecode >>\nstream\n3c3c3e3e>\nendstream"), ErrUnverifiable},
+		{"object stream rusak", pdf(catalog, "<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode >>\nstream\nbukan zlib\nendstream"), ErrUnverifiable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mimeType, err := inspect(t, "dokumen.pdf", tc.data)
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && mimeType != "application/pdf" {
+				t.Fatalf("MimeType = %s", mimeType)
+			}
+		})
+	}
+}
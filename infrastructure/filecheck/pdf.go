@@ -0,0 +1,289 @@
+package filecheck
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+)
+
+// Batas pemeriksaan object stream. Object stream hanya berisi kamus objek
+// sehingga ukurannya kecil pada PDF normal; yang melewati batas ditolak
+// karena isinya tidak bisa dipastikan bebas JavaScript.
+const (
+	maxObjStmSize     = 16 << 20
+	maxObjStmInflated = 64 << 20
+	maxPDFNameLen     = 127
+	maxPDFKeywordLen  = 16
+)
+
+// pdfScriptNames adalah nama PDF yang menandakan JavaScript: kunci /JS pada
+// aksi dan tipe aksi /S /JavaScript maupun pohon /Names /JavaScript.
+var pdfScriptNames = map[string]bool{"JavaScript": true, "JS": true}
+
+// Filter yang dikenali pada kamus object stream. Object stream tanpa filter
+// sudah terbaca apa adanya, FlateDecode di-inflate, sisanya (termasuk
+// DecodeParms/predictor) tidak didukung.
+var (
+	pdfFlateFilters = map[string]bool{"FlateDecode": true, "Fl": true}
+	pdfOtherFilters = map[string]bool{
+		"ASCIIHexDecode": true, "AHx": true,
+		"ASCII85Decode": true, "A85": true,
+		"LZWDecode": true, "LZW": true,
+		"RunLengthDecode": true, "RL": true,
+		"Crypt": true,
+	}
+)
+
+// pdfScanner memeriksa PDF yang datang per potongan. Nama PDF didecode
+// (escape #xx, misalnya /J#61vaScript) sebelum dicocokkan, dan isi object
+// stream (/Type /ObjStm) yang dikompresi FlateDecode di-inflate lalu
+// diperiksa dengan cara yang sama, karena kamus aksi bisa disimpan di sana.
+type pdfScanner struct {
+	names pdfLexer
+	found bool
+	err   error
+
+	// status kamus objek yang sedang dibaca, direset pada "endobj"
+	objStm      bool
+	flate       bool
+	unsupported bool
+
+	// isi object stream terkompresi yang sedang ditampung
+	capturing bool
+	skipEOL   bool
+	captured  []byte
+}
+
+func newPDFScanner() *pdfScanner {
+	s := &pdfScanner{}
+	s.names.onName = s.name
+	s.names.onKeyword = s.keyword
+	return s
+}
+
+func (s *pdfScanner) write(p []byte) {
+	if s.found || s.err != nil {
+		return
+	}
+
+	for i := 0; i < len(p) && !s.found && s.err == nil; i++ {
+		c := p[i]
+
+		if s.capturing {
+			if s.skipEOL {
+				s.skipEOL = false
+				if c == '\n' {
+					continue
+				}
+			}
+			if len(s.captured) >= maxObjStmSize {
+				s.err = ErrUnverifiable
+				return
+			}
+			s.captured = append(s.captured, c)
+		}
+
+		s.names.feed(c)
+	}
+}
+
+// finish dipanggil di akhir file. Object stream yang belum selesai ditampung
+// berarti file terpotong atau "endstream" tidak ditemukan.
+func (s *pdfScanner) finish() {
+	if s.found || s.err != nil {
+		return
+	}
+
+	s.names.flush()
+	if s.capturing && !s.found && s.err == nil {
+		s.inflate(true)
+	}
+}
+
+func (s *pdfScanner) name(name []byte) {
+	switch n := string(name); {
+	case pdfScriptNames[n]:
+		s.found = true
+	case n == "ObjStm":
+		s.objStm = true
+	case pdfFlateFilters[n]:
+		s.flate = true
+	case pdfOtherFilters[n], n == "DecodeParms":
+		s.unsupported = true
+	}
+}
+
+func (s *pdfScanner) keyword(keyword []byte) {
+	switch string(keyword) {
+	case "stream":
+		if s.capturing || !s.objStm {
+			return
+		}
+		if s.unsupported {
+			s.err = ErrUnverifiable
+			return
+		}
+		if s.flate {
+			// Data stream dimulai setelah CRLF atau LF sesudah "stream".
+			// Delimiter keyword sudah terbaca; jika itu CR, LF berikutnya
+			// dilewati di write.
+			s.capturing, s.skipEOL, s.captured = true, s.names.delimiter == '\r', s.captured[:0]
+		}
+	case "endstream":
+		if s.capturing {
+			// "endstream" sudah ikut tertampung; zlib berhenti di akhir
+			// datanya sendiri sehingga sisa byte tidak berpengaruh.
+			s.inflate(false)
+		}
+	case "endobj":
+		s.objStm, s.flate, s.unsupported = false, false, false
+	}
+}
+
+// inflate memeriksa object stream yang sudah tertampung. Jika datanya belum
+// lengkap, "endstream" tadi kemungkinan hanya kebetulan muncul di data
+// terkompresi, jadi penampungan dilanjutkan kecuali file sudah habis.
+func (s *pdfScanner) inflate(final bool) {
+	zr, err := zlib.NewReader(bytes.NewReader(s.captured))
+	if err != nil {
+		s.err = ErrUnverifiable
+		return
+	}
+
+	inner := &pdfScanner{}
+	inner.names.onName = inner.name
+	inner.names.onKeyword = func([]byte) {}
+
+	buf := make([]byte, 32*1024)
+	var total int64
+	for !inner.found {
+		n, err := zr.Read(buf)
+		total += int64(n)
+		if total > maxObjStmInflated {
+			s.err = ErrUnverifiable
+			return
+		}
+		for _, c := range buf[:n] {
+			inner.names.feed(c)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) && !final {
+				return
+			}
+			s.err = ErrUnverifiable
+			return
+		}
+	}
+	inner.names.flush()
+
+	s.found = inner.found
+	s.capturing, s.captured = false, nil
+}
+
+// pdfLexer memecah byte PDF menjadi nama (diawali "/") dan keyword (token
+// biasa seperti "stream" atau "endobj"). Token yang melebihi batas panjang
+// tidak dilaporkan karena tidak mungkin sama dengan yang dicari.
+type pdfLexer struct {
+	onName    func([]byte)
+	onKeyword func([]byte)
+
+	inName    bool
+	token     []byte
+	tooLong   bool
+	delimiter byte // delimiter yang sedang mengakhiri token
+
+	// escape #xx pada nama: hex adalah jumlah digit yang sudah terbaca, -1
+	// jika tidak sedang dalam escape
+	hex    int
+	hexRaw []byte
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '/', '<', '>', '[', ']', '(', ')', '{', '}', '%':
+		return true
+	}
+	return false
+}
+
+func (l *pdfLexer) feed(c byte) {
+	if l.inName && l.hex >= 0 {
+		if _, ok := hexValue(c); ok {
+			l.hexRaw = append(l.hexRaw, c)
+			if l.hex++; l.hex == 2 {
+				hi, _ := hexValue(l.hexRaw[1])
+				lo, _ := hexValue(l.hexRaw[2])
+				l.add(hi<<4 | lo)
+				l.hex = -1
+			}
+			return
+		}
+		// escape tidak valid: "#" dan digit sebelumnya dipakai apa adanya
+		for _, raw := range l.hexRaw {
+			l.add(raw)
+		}
+		l.hex = -1
+	}
+
+	if isPDFDelimiter(c) {
+		l.delimiter = c
+		l.flush()
+		if c == '/' {
+			l.inName = true
+		}
+		return
+	}
+
+	if l.inName && c == '#' {
+		l.hex, l.hexRaw = 0, append(l.hexRaw[:0], '#')
+		return
+	}
+
+	l.add(c)
+}
+
+func (l *pdfLexer) add(c byte) {
+	limit := maxPDFKeywordLen
+	if l.inName {
+		limit = maxPDFNameLen
+	}
+	if len(l.token) >= limit {
+		l.tooLong = true
+		return
+	}
+	l.token = append(l.token, c)
+}
+
+// flush mengakhiri token yang sedang dibaca.
+func (l *pdfLexer) flush() {
+	if l.inName && l.hex >= 0 {
+		for _, raw := range l.hexRaw {
+			l.add(raw)
+		}
+	}
+
+	if !l.tooLong {
+		if l.inName {
+			l.onName(l.token)
+		} else if len(l.token) > 0 {
+			l.onKeyword(l.token)
+		}
+	}
+	l.inName, l.tooLong, l.hex, l.token = false, false, -1, l.token[:0]
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -12,9 +12,12 @@ func CORSMiddleware() gin.HandlerFunc {
 		AllowOrigins: []string{
 			"https://frontend-staffpriv-docs.vercel.app",
 		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Device"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "HEAD"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Authorization", "X-Device",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"},
+		ExposeHeaders: []string{"Content-Length", "Content-Disposition", "Content-Range", "Accept-Ranges", "ETag",
+			"Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Location", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Document-Id"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -0,0 +1,66 @@
+package pdfbuild
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// A4 dalam satuan point.
+var (
+	a4Portrait  = types.Dim{Width: 595, Height: 842}
+	a4Landscape = types.Dim{Width: 842, Height: 595}
+)
+
+func init() {
+	// Tanpa ini pdfcpu membuat config.yml di home directory dan menghentikan
+	// proses jika gagal.
+	model.ConfigPath = "disable"
+}
+
+func configuration() *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	return conf
+}
+
+// ImageToPDF menulis satu gambar (JPEG, PNG, GIF, TIFF, WebP) sebagai PDF
+// satu halaman A4. Gambar diletakkan di tengah dan diperkecil agar muat
+// dengan margin; halaman dibuat landscape jika gambar lebih lebar.
+func ImageToPDF(w io.Writer, img io.Reader, landscape bool) error {
+	imp := pdfcpu.DefaultImportConfig()
+	imp.PageDim = &a4Portrait
+	if landscape {
+		imp.PageDim = &a4Landscape
+	}
+	imp.Pos = types.Center
+	imp.Scale = 0.95
+
+	if err := api.ImportImages(nil, w, []io.Reader{img}, imp, configuration()); err != nil {
+		return fmt.Errorf("gagal membuat halaman PDF: %v", err)
+	}
+
+	return nil
+}
+
+// Merge menggabungkan beberapa PDF sesuai urutan inputs.
+func Merge(w io.Writer, inputs []io.ReadSeeker) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("tidak ada PDF untuk digabung")
+	}
+
+	if err := api.MergeRaw(inputs, w, false, configuration()); err != nil {
+		return fmt.Errorf("gagal menggabungkan PDF: %v", err)
+	}
+
+	return nil
+}
+
+// PageCount mengembalikan jumlah halaman PDF.
+func PageCount(rs io.ReadSeeker) (int, error) {
+	return api.PageCount(rs, configuration())
+}
@@ -16,8 +16,41 @@ func DocumentStaffRoutes(r *gin.Engine) {
 
 		ds.PATCH("/my-documents/:id", documentStaffController.UpdateMyDocumentStaff)
 
+		ds.GET("/search", documentStaffController.SearchDocumentsStaff)
+
+		ds.GET("/trash", documentStaffController.GetTrashDocumentsStaff)
+
+		ds.POST("/trash/:id/restore", documentStaffController.RestoreDocumentStaff)
+
+		ds.GET("/:id/download", documentStaffController.DownloadDocumentStaff)
+
+		ds.GET("/:id/url", documentStaffController.GetDocumentStaffURL)
+
+		ds.GET("/:id/thumbnail", documentStaffController.GetDocumentThumbnail)
+
+		ds.GET("/:id/text", documentStaffController.GetDocumentText)
+
+		ds.GET("/:id/versions", documentStaffController.GetDocumentVersions)
+
+		ds.GET("/:id/versions/:versionId/download", documentStaffController.DownloadDocumentVersion)
+
+		ds.POST("/:id/versions/:versionId/rollback", documentStaffController.RollbackDocumentVersion)
+
 		ds.DELETE("/:id", documentStaffController.DeleteDocumentStaff)
 
+		tus := ds.Group("/tus")
+		{
+			tus.OPTIONS("", documentStaffController.TusOptions)
+
+			tus.POST("", documentStaffController.TusCreateUpload)
+
+			tus.HEAD("/:uploadId", documentStaffController.TusHeadUpload)
+
+			tus.PATCH("/:uploadId", documentStaffController.TusPatchUpload)
+
+			tus.DELETE("/:uploadId", documentStaffController.TusDeleteUpload)
+		}
+
 		adminGroup := ds.Group("")
 		adminGroup.Use(middleware.AdminMiddleware())
 		{
@@ -25,7 +58,23 @@ func DocumentStaffRoutes(r *gin.Engine) {
 
 			adminGroup.GET("/", documentStaffController.GetAllDocumentsStaffAdmin)
 
+			adminGroup.GET("/export", documentStaffController.ExportDocumentsStaffAdmin)
+
+			adminGroup.POST("/merge", documentStaffController.MergeDocumentsStaffAdmin)
+
+			adminGroup.POST("/map-document-types", documentStaffController.MapDocumentTypesAdmin)
+
 			adminGroup.PATCH("/:id", documentStaffController.UpdateDocumentStaffAdmin)
+
+			adminGroup.GET("/:id/access-logs", documentStaffController.GetDocumentAccessLogs)
+
+			adminGroup.POST("/:id/verify", documentStaffController.VerifyDocumentStaffAdmin)
+
+			adminGroup.GET("/:id/integrity-checks", documentStaffController.GetDocumentIntegrityChecks)
+
+			adminGroup.GET("/storage-deletions", documentStaffController.GetStorageDeletions)
+
+			adminGroup.POST("/storage-deletions/:id/retry", documentStaffController.RetryStorageDeletionAdmin)
 		}
 	}
 }
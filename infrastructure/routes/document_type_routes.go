@@ -0,0 +1,28 @@
+package routes
+
+import (
+	documentTypeController "BackendKantorDinsos/domain/document_type"
+	"BackendKantorDinsos/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DocumentTypeRoutes(r *gin.Engine) {
+
+	dt := r.Group("/api/document_types", middleware.AuthMiddleware())
+	{
+		dt.GET("/", documentTypeController.GetDocumentTypes)
+
+		dt.GET("/:id", documentTypeController.GetDocumentType)
+
+		adminGroup := dt.Group("")
+		adminGroup.Use(middleware.AdminMiddleware())
+		{
+			adminGroup.POST("/", documentTypeController.CreateDocumentType)
+
+			adminGroup.PUT("/:id", documentTypeController.UpdateDocumentType)
+
+			adminGroup.DELETE("/:id", documentTypeController.DeleteDocumentType)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	storageQuotaController "BackendKantorDinsos/domain/storage_quota"
+	"BackendKantorDinsos/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StorageQuotaRoutes(r *gin.Engine) {
+
+	quota := r.Group("/api/storage", middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		quota.GET("/report", storageQuotaController.GetStorageReport)
+
+		quota.PUT("/quota", storageQuotaController.SetDefaultQuota)
+
+		quota.GET("/quota/:employeeId", storageQuotaController.GetEmployeeUsage)
+
+		quota.PUT("/quota/:employeeId", storageQuotaController.SetEmployeeQuota)
+
+		quota.DELETE("/quota/:employeeId", storageQuotaController.DeleteEmployeeQuota)
+	}
+}
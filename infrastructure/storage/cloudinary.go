@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"BackendKantorDinsos/infrastructure/config"
+)
+
+// legacyDeliveryType adalah tipe delivery file lama yang diupload sebelum
+// mode privat diaktifkan.
+const legacyDeliveryType = "upload"
+
+type CloudinaryStorage struct {
+	client       *http.Client
+	deliveryType string
+}
+
+func NewCloudinaryStorage() *CloudinaryStorage {
+	return &CloudinaryStorage{
+		client:       newStreamingClient(),
+		deliveryType: config.CloudinaryDeliveryType(),
+	}
+}
+
+func (s *CloudinaryStorage) private() bool {
+	return s.deliveryType != legacyDeliveryType
+}
+
+func (s *CloudinaryStorage) Put(file io.Reader, opts PutOptions) (Object, error) {
+	result, err := config.UploadToCloudinary(file, opts.FileName, opts.Folder, opts.ResourceType, s.deliveryType)
+	if err != nil {
+		return Object{}, err
+	}
+
+	object := Object{
+		PublicID:     result.PublicID,
+		URL:          result.SecureURL,
+		ResourceType: opts.ResourceType,
+	}
+
+	// secure_url asset privat tidak bisa dibuka langsung, jadi tidak disimpan.
+	if s.private() {
+		object.URL = ""
+	}
+
+	return object, nil
+}
+
+// Delete juga mencoba tipe delivery lama agar file yang belum dimigrasi
+// tetap ikut terhapus.
+func (s *CloudinaryStorage) Delete(publicID, resourceType string) error {
+	err := config.DeleteFromCloudinary(publicID, resourceType, s.deliveryType)
+	if errors.Is(err, config.ErrCloudinaryNotFound) && s.private() {
+		err = config.DeleteFromCloudinary(publicID, resourceType, legacyDeliveryType)
+	}
+
+	if errors.Is(err, config.ErrCloudinaryNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *CloudinaryStorage) Exists(publicID, resourceType string) (bool, error) {
+	if config.CloudinaryFileExists(publicID, resourceType, s.deliveryType) {
+		return true, nil
+	}
+
+	if s.private() {
+		return config.CloudinaryFileExists(publicID, resourceType, legacyDeliveryType), nil
+	}
+
+	return false, nil
+}
+
+func (s *CloudinaryStorage) List(folder, resourceType string) ([]ListedObject, error) {
+	resources, err := config.ListCloudinaryResources(folder+"/", resourceType, s.deliveryType)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.private() {
+		legacy, err := config.ListCloudinaryResources(folder+"/", resourceType, legacyDeliveryType)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, legacy...)
+	}
+
+	objects := make([]ListedObject, 0, len(resources))
+	for _, resource := range resources {
+		objects = append(objects, ListedObject{PublicID: resource.PublicID, CreatedAt: resource.CreatedAt})
+	}
+
+	return objects, nil
+}
+
+// URL mengembalikan URL publik permanen. Untuk mode privat tidak ada URL
+// publik, gunakan SignedURL.
+func (s *CloudinaryStorage) URL(publicID, resourceType string) (string, error) {
+	if s.private() {
+		return "", nil
+	}
+
+	return s.publicURL(publicID, resourceType)
+}
+
+func (s *CloudinaryStorage) publicURL(publicID, resourceType string) (string, error) {
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	if cloudName == "" {
+		return "", fmt.Errorf("CLOUDINARY_CLOUD_NAME belum diisi")
+	}
+
+	return fmt.Sprintf("https://res.cloudinary.com/%s/%s/upload/%s", cloudName, resourceType, publicID), nil
+}
+
+// SignedURL membuat URL download yang kedaluwarsa setelah ttl. Dalam mode
+// publik URL biasa dikembalikan apa adanya. Dalam mode privat URL hanya
+// berlaku untuk file yang sudah dimigrasi; memastikan tipe delivery tiap file
+// butuh satu panggilan Admin API per URL, jadi tidak dilakukan di sini.
+// Download lewat Open dan OpenRange tetap mencoba tipe lama.
+func (s *CloudinaryStorage) SignedURL(publicID, resourceType string, ttl time.Duration) (string, error) {
+	if !s.private() {
+		return s.publicURL(publicID, resourceType)
+	}
+
+	return config.CloudinaryPrivateDownloadURL(publicID, resourceType, s.deliveryType, time.Now().Add(ttl))
+}
+
+// MakePrivate memindahkan asset publik lama ke tipe delivery yang sedang
+// dipakai.
+func (s *CloudinaryStorage) MakePrivate(publicID, resourceType string) (Object, error) {
+	if !s.private() {
+		return Object{}, fmt.Errorf("CLOUDINARY_DELIVERY_TYPE masih %q", legacyDeliveryType)
+	}
+
+	if _, err := config.RenameCloudinaryResource(publicID, resourceType, legacyDeliveryType, s.deliveryType); err != nil {
+		return Object{}, err
+	}
+
+	return Object{PublicID: publicID, ResourceType: resourceType}, nil
+}
+
+func (s *CloudinaryStorage) Open(publicID, resourceType string) (io.ReadCloser, error) {
+	return s.OpenRange(publicID, resourceType, 0)
+}
+
+func (s *CloudinaryStorage) Size(publicID, resourceType string) (int64, error) {
+	size, err := config.CloudinaryResourceBytes(publicID, resourceType, s.deliveryType)
+	if errors.Is(err, config.ErrCloudinaryNotFound) && s.private() {
+		size, err = config.CloudinaryResourceBytes(publicID, resourceType, legacyDeliveryType)
+	}
+
+	return size, err
+}
+
+// OpenRange juga mencoba URL publik tipe delivery lama jika file tidak
+// ditemukan, sama seperti Delete dan Size, agar file yang belum dimigrasi
+// tetap bisa diunduh.
+func (s *CloudinaryStorage) OpenRange(publicID, resourceType string, offset int64) (io.ReadCloser, error) {
+	url, err := s.SignedURL(publicID, resourceType, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.openURL(url, offset)
+	if errors.Is(err, errHTTPNotFound) && s.private() {
+		if url, err = s.publicURL(publicID, resourceType); err != nil {
+			return nil, err
+		}
+		body, err = s.openURL(url, offset)
+	}
+
+	if errors.Is(err, errHTTPNotFound) {
+		return nil, config.ErrCloudinaryNotFound
+	}
+
+	return body, err
+}
+
+func (s *CloudinaryStorage) openURL(url string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := openHTTPRange(s.client, req, offset)
+	if err != nil && !errors.Is(err, errHTTPNotFound) {
+		return nil, fmt.Errorf("file tidak dapat diambil dari Cloudinary: %v", err)
+	}
+
+	return body, err
+}
@@ -0,0 +1,445 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// File dienkripsi per segmen agar bisa dialirkan dan dibaca sebagian
+	// (HTTP Range) tanpa mendekripsi seluruh file.
+	encryptedSegmentSize = 64 * 1024
+	encryptedTagSize     = 16
+	encryptedHeader      = "DSE1"
+)
+
+var errCorruptedCiphertext = errors.New("file terenkripsi rusak atau terpotong")
+
+// EncryptedStorage membungkus storage lain dengan envelope encryption:
+// setiap file dienkripsi AES-256-GCM memakai data key acak, dan data key
+// tersebut dibungkus master key lalu disimpan di tabel data_keys.
+//
+// File terenkripsi selalu disimpan sebagai resource type "raw" karena
+// isinya bukan lagi gambar yang valid. File lama tanpa data key dibaca apa
+// adanya.
+type EncryptedStorage struct {
+	inner   Storage
+	keyring *Keyring
+	db      *gorm.DB
+}
+
+// WithEncryption membungkus storage jika STORAGE_MASTER_KEYS diisi. Jika
+// tidak, storage dikembalikan tanpa perubahan.
+func WithEncryption(inner Storage, db *gorm.DB) (Storage, error) {
+	keyring, err := LoadKeyringFromEnv()
+	if err != nil || keyring == nil {
+		return inner, err
+	}
+
+	return &EncryptedStorage{inner: inner, keyring: keyring, db: db}, nil
+}
+
+func (s *EncryptedStorage) Put(file io.Reader, opts PutOptions) (Object, error) {
+	dataKey := make([]byte, 32)
+	noncePrefix := make([]byte, 8)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Object{}, err
+	}
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return Object{}, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return Object{}, err
+	}
+
+	keyID, wrapped, err := s.keyring.wrap(dataKey)
+	if err != nil {
+		return Object{}, err
+	}
+
+	innerOpts := opts
+	innerOpts.ResourceType = "raw"
+
+	object, err := s.inner.Put(newEncryptReader(file, aead, noncePrefix), innerOpts)
+	if err != nil {
+		return Object{}, err
+	}
+
+	if err := s.db.Create(&DataKey{
+		PublicID:    object.PublicID,
+		KeyID:       keyID,
+		WrappedKey:  wrapped,
+		NoncePrefix: noncePrefix,
+	}).Error; err != nil {
+		s.inner.Delete(object.PublicID, "raw")
+		return Object{}, fmt.Errorf("gagal menyimpan data key: %v", err)
+	}
+
+	// Isi file hanya bisa dibaca lewat backend, jadi tidak ada URL langsung.
+	return Object{PublicID: object.PublicID, ResourceType: opts.ResourceType}, nil
+}
+
+func (s *EncryptedStorage) Delete(publicID, resourceType string) error {
+	dataKey, err := findDataKey(s.db, publicID)
+	if err != nil {
+		return err
+	}
+
+	if dataKey == nil {
+		return s.inner.Delete(publicID, resourceType)
+	}
+
+	if err := s.inner.Delete(publicID, "raw"); err != nil {
+		return err
+	}
+
+	return s.db.Delete(dataKey).Error
+}
+
+func (s *EncryptedStorage) Exists(publicID, resourceType string) (bool, error) {
+	innerType, err := s.innerType(publicID, resourceType)
+	if err != nil {
+		return false, err
+	}
+
+	return s.inner.Exists(publicID, innerType)
+}
+
+func (s *EncryptedStorage) URL(publicID, resourceType string) (string, error) {
+	return "", nil
+}
+
+func (s *EncryptedStorage) Open(publicID, resourceType string) (io.ReadCloser, error) {
+	dataKey, err := findDataKey(s.db, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dataKey == nil {
+		return s.inner.Open(publicID, resourceType)
+	}
+
+	aead, err := s.dataKeyAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.inner.Open(publicID, "raw")
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(encryptedHeader))
+	if _, err := io.ReadFull(body, header); err != nil || string(header) != encryptedHeader {
+		body.Close()
+		return nil, errCorruptedCiphertext
+	}
+
+	return newDecryptReader(body, aead, dataKey.NoncePrefix, 0), nil
+}
+
+func (s *EncryptedStorage) Size(publicID, resourceType string) (int64, error) {
+	dataKey, err := findDataKey(s.db, publicID)
+	if err != nil {
+		return 0, err
+	}
+
+	opener, ok := s.inner.(RangeOpener)
+	if !ok {
+		return 0, fmt.Errorf("storage tidak mendukung pembacaan sebagian")
+	}
+
+	if dataKey == nil {
+		return opener.Size(publicID, resourceType)
+	}
+
+	size, err := opener.Size(publicID, "raw")
+	if err != nil {
+		return 0, err
+	}
+
+	body := size - int64(len(encryptedHeader))
+	if body < encryptedTagSize {
+		return 0, errCorruptedCiphertext
+	}
+
+	segmentLen := int64(encryptedSegmentSize + encryptedTagSize)
+	segments := (body + segmentLen - 1) / segmentLen
+
+	return body - segments*encryptedTagSize, nil
+}
+
+func (s *EncryptedStorage) OpenRange(publicID, resourceType string, offset int64) (io.ReadCloser, error) {
+	dataKey, err := findDataKey(s.db, publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	opener, ok := s.inner.(RangeOpener)
+	if !ok {
+		return nil, fmt.Errorf("storage tidak mendukung pembacaan sebagian")
+	}
+
+	if dataKey == nil {
+		return opener.OpenRange(publicID, resourceType, offset)
+	}
+
+	aead, err := s.dataKeyAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Offset tepat di batas segmen dimulai dari segmen sebelumnya, karena
+	// offset di akhir file yang panjangnya kelipatan ukuran segmen tidak
+	// memiliki segmen sendiri.
+	segment := offset / encryptedSegmentSize
+	if offset > 0 && offset%encryptedSegmentSize == 0 {
+		segment--
+	}
+	innerOffset := int64(len(encryptedHeader)) + segment*(encryptedSegmentSize+encryptedTagSize)
+
+	body, err := opener.OpenRange(publicID, "raw", innerOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := newDecryptReader(body, aead, dataKey.NoncePrefix, uint32(segment))
+	if _, err := io.CopyN(io.Discard, reader, offset-segment*encryptedSegmentSize); err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+func (s *EncryptedStorage) List(folder, resourceType string) ([]ListedObject, error) {
+	lister, ok := s.inner.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("storage tidak mendukung listing file")
+	}
+
+	objects, err := lister.List(folder, resourceType)
+	if err != nil || resourceType == "raw" {
+		return objects, err
+	}
+
+	encrypted, err := lister.List(folder, "raw")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(objects, encrypted...), nil
+}
+
+func (s *EncryptedStorage) MakePrivate(publicID, resourceType string) (Object, error) {
+	privatizer, ok := s.inner.(Privatizer)
+	if !ok {
+		return Object{}, fmt.Errorf("storage tidak mendukung migrasi delivery privat")
+	}
+
+	return privatizer.MakePrivate(publicID, resourceType)
+}
+
+// RotateKeys membungkus ulang data key yang masih memakai master key lama
+// dengan master key aktif. Isi file di storage tidak disentuh.
+func (s *EncryptedStorage) RotateKeys() (int, error) {
+	var dataKeys []DataKey
+	if err := s.db.Where("key_id <> ?", s.keyring.activeID).Find(&dataKeys).Error; err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, dataKey := range dataKeys {
+		plain, err := s.keyring.unwrap(dataKey.KeyID, dataKey.WrappedKey)
+		if err != nil {
+			return rotated, fmt.Errorf("data key %s: %v", dataKey.PublicID, err)
+		}
+
+		keyID, wrapped, err := s.keyring.wrap(plain)
+		if err != nil {
+			return rotated, err
+		}
+
+		if err := s.db.Model(&dataKey).Updates(map[string]interface{}{
+			"key_id":      keyID,
+			"wrapped_key": wrapped,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+
+	return rotated, nil
+}
+
+func (s *EncryptedStorage) dataKeyAEAD(dataKey *DataKey) (cipher.AEAD, error) {
+	plain, err := s.keyring.unwrap(dataKey.KeyID, dataKey.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGCM(plain)
+}
+
+// innerType menentukan resource type yang dipakai di storage asli.
+func (s *EncryptedStorage) innerType(publicID, resourceType string) (string, error) {
+	dataKey, err := findDataKey(s.db, publicID)
+	if err != nil {
+		return "", err
+	}
+
+	if dataKey != nil {
+		return "raw", nil
+	}
+
+	return resourceType, nil
+}
+
+// segmentNonce menyusun nonce 12 byte dari prefix acak dan nomor segmen.
+func segmentNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[8:], counter)
+	return nonce
+}
+
+// segmentAAD menandai segmen terakhir sehingga file yang terpotong di batas
+// segmen tetap terdeteksi.
+func segmentAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type encryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	out     []byte
+	started bool
+	done    bool
+}
+
+func newEncryptReader(src io.Reader, aead cipher.AEAD, prefix []byte) *encryptReader {
+	return &encryptReader{
+		src:    bufio.NewReaderSize(src, encryptedSegmentSize),
+		aead:   aead,
+		prefix: prefix,
+		plain:  make([]byte, encryptedSegmentSize),
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if !r.started {
+			r.started = true
+			r.out = []byte(encryptedHeader)
+			break
+		}
+
+		n, err := io.ReadFull(r.src, r.plain)
+		final := false
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			final = true
+		case err != nil:
+			return 0, err
+		default:
+			if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+				final = true
+			} else if peekErr != nil {
+				return 0, peekErr
+			}
+		}
+
+		r.out = r.aead.Seal(r.out[:0], segmentNonce(r.prefix, r.counter), r.plain[:n], segmentAAD(final))
+		r.counter++
+		r.done = final
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+type decryptReader struct {
+	body    io.ReadCloser
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	sealed  []byte
+	out     []byte
+	done    bool
+}
+
+func newDecryptReader(body io.ReadCloser, aead cipher.AEAD, prefix []byte, counter uint32) *decryptReader {
+	return &decryptReader{
+		body:    body,
+		src:     bufio.NewReaderSize(body, encryptedSegmentSize+encryptedTagSize),
+		aead:    aead,
+		prefix:  prefix,
+		counter: counter,
+		sealed:  make([]byte, encryptedSegmentSize+encryptedTagSize),
+	}
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.src, r.sealed)
+		final := false
+		switch {
+		case err == io.EOF:
+			return 0, errCorruptedCiphertext
+		case err == io.ErrUnexpectedEOF:
+			final = true
+		case err != nil:
+			return 0, err
+		default:
+			if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+				final = true
+			} else if peekErr != nil {
+				return 0, peekErr
+			}
+		}
+
+		plain, err := r.aead.Open(r.out[:0], segmentNonce(r.prefix, r.counter), r.sealed[:n], segmentAAD(final))
+		if err != nil {
+			return 0, errCorruptedCiphertext
+		}
+
+		r.out = plain
+		r.counter++
+		r.done = final
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.body.Close()
+}
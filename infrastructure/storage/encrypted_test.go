@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDataKeys adalah tabel data_keys di memori yang diakses gorm lewat
+// driver database/sql minimal. Hanya query yang dipakai EncryptedStorage
+// yang dikenali; query lain membuat test gagal.
+type fakeDataKeys struct {
+	mu   sync.Mutex
+	rows map[string]map[string]driver.Value
+}
+
+var dataKeyColumns = []string{"public_id", "key_id", "wrapped_key", "nonce_prefix", "created_at", "updated_at"}
+
+var (
+	insertQuery = regexp.MustCompile("^INSERT INTO `data_keys` \\((.+?)\\) VALUES \\((.+)\\)")
+	updateQuery = regexp.MustCompile("^UPDATE `data_keys` SET (.+) WHERE .*public_id.? = \\?")
+)
+
+func (f *fakeDataKeys) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDataKeys) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ table *fakeDataKeys }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.table, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c fakeConn) Commit() error                             { return nil }
+func (c fakeConn) Rollback() error                           { return nil }
+
+type fakeStmt struct {
+	table *fakeDataKeys
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	f := s.table
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case insertQuery.MatchString(s.query):
+		columns := strings.Split(insertQuery.FindStringSubmatch(s.query)[1], ",")
+		row := map[string]driver.Value{}
+		for i, column := range columns {
+			row[strings.Trim(column, "` ")] = args[i]
+		}
+		f.rows[row["public_id"].(string)] = row
+		return driver.RowsAffected(1), nil
+
+	case updateQuery.MatchString(s.query):
+		row, ok := f.rows[args[len(args)-1].(string)]
+		if !ok {
+			return driver.RowsAffected(0), nil
+		}
+		for i, assignment := range strings.Split(updateQuery.FindStringSubmatch(s.query)[1], ",") {
+			column, _, _ := strings.Cut(assignment, "=")
+			row[strings.Trim(column, "` ")] = args[i]
+		}
+		return driver.RowsAffected(1), nil
+
+	case strings.HasPrefix(s.query, "DELETE FROM `data_keys`"):
+		publicID := args[len(args)-1].(string)
+		if _, ok := f.rows[publicID]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		delete(f.rows, publicID)
+		return driver.RowsAffected(1), nil
+	}
+
+	return nil, fmt.Errorf("query tidak dikenal: %s", s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	f := s.table
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(s.query, "SELECT * FROM `data_keys` WHERE") {
+		return nil, fmt.Errorf("query tidak dikenal: %s", s.query)
+	}
+
+	var match func(row map[string]driver.Value) bool
+	switch {
+	case strings.Contains(s.query, "public_id = ?"):
+		match = func(row map[string]driver.Value) bool { return row["public_id"] == args[0] }
+	case strings.Contains(s.query, "key_id <> ?"):
+		match = func(row map[string]driver.Value) bool { return row["key_id"] != args[0] }
+	default:
+		return nil, fmt.Errorf("query tidak dikenal: %s", s.query)
+	}
+
+	ids := make([]string, 0, len(f.rows))
+	for id := range f.rows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rows := &fakeRows{}
+	for _, id := range ids {
+		if row := f.rows[id]; match(row) {
+			values := make([]driver.Value, len(dataKeyColumns))
+			for i, column := range dataKeyColumns {
+				values[i] = row[column]
+			}
+			rows.values = append(rows.values, values)
+		}
+	}
+
+	return rows, nil
+}
+
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return dataKeyColumns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newFakeDB(t *testing.T) (*gorm.DB, *fakeDataKeys) {
+	t.Helper()
+
+	table := &fakeDataKeys{rows: map[string]map[string]driver.Value{}}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(table),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+
+	return db, table
+}
+
+func masterKey() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// newEncrypted memasang EncryptedStorage di atas LocalStorage dengan master
+// key dari env, seperti saat aplikasi berjalan.
+func newEncrypted(t *testing.T, inner *LocalStorage, db *gorm.DB, keys, activeID string) *EncryptedStorage {
+	t.Helper()
+
+	t.Setenv("STORAGE_MASTER_KEYS", keys)
+	t.Setenv("STORAGE_MASTER_KEY_ID", activeID)
+
+	s, err := WithEncryption(inner, db)
+	if err != nil {
+		t.Fatalf("WithEncryption: %v", err)
+	}
+
+	encrypted, ok := s.(*EncryptedStorage)
+	if !ok {
+		t.Fatalf("WithEncryption mengembalikan %T", s)
+	}
+
+	return encrypted
+}
+
+func newLocal(t *testing.T) *LocalStorage {
+	t.Helper()
+
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	return local
+}
+
+// readAll membaca seluruh hasil Open atau OpenRange.
+func readAll(r io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	db, _ := newFakeDB(t)
+	local := newLocal(t)
+	s := newEncrypted(t, local, db, "k1:"+masterKey(), "")
+
+	sizes := []int{0, 1, encryptedSegmentSize - 1, encryptedSegmentSize, encryptedSegmentSize + 1, 3*encryptedSegmentSize + 123}
+	for _, size := range sizes {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			data := make([]byte, size)
+			rand.Read(data)
+
+			object, err := s.Put(bytes.NewReader(data), PutOptions{FileName: "scan.pdf", Folder: "dokumen", ResourceType: "image", OwnerID: "pegawai"})
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if object.ResourceType != "image" {
+				t.Fatalf("ResourceType = %s, want image", object.ResourceType)
+			}
+
+			stored, err := os.ReadFile(mustPath(t, local, object.PublicID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size >= 16 && bytes.Contains(stored, data[:16]) {
+				t.Fatal("isi file tersimpan tanpa enkripsi")
+			}
+
+			got, err := readAll(s.Open(object.PublicID, "image"))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("Open: %d byte, err %v, want %d byte", len(got), err, size)
+			}
+
+			n, err := s.Size(object.PublicID, "image")
+			if err != nil || n != int64(size) {
+				t.Fatalf("Size = %d, %v, want %d", n, err, size)
+			}
+
+			for _, offset := range []int{0, 1, encryptedSegmentSize - 1, encryptedSegmentSize, encryptedSegmentSize + 1, size} {
+				if offset > size {
+					continue
+				}
+				got, err := readAll(s.OpenRange(object.PublicID, "image", int64(offset)))
+				if err != nil || !bytes.Equal(got, data[offset:]) {
+					t.Fatalf("OpenRange(%d): %d byte, err %v, want %d byte", offset, len(got), err, size-offset)
+				}
+			}
+		})
+	}
+}
+
+func mustPath(t *testing.T, local *LocalStorage, publicID string) string {
+	t.Helper()
+
+	path, err := local.path(publicID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptedRejectsTampering(t *testing.T) {
+	db, _ := newFakeDB(t)
+	local := newLocal(t)
+	s := newEncrypted(t, local, db, "k1:"+masterKey(), "")
+
+	data := make([]byte, 2*encryptedSegmentSize+100)
+	rand.Read(data)
+
+	object, err := s.Put(bytes.NewReader(data), PutOptions{FileName: "scan.pdf", Folder: "dokumen", ResourceType: "raw", OwnerID: "pegawai"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	path := mustPath(t, local, object.PublicID)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := len(encryptedHeader)
+	segment := encryptedSegmentSize + encryptedTagSize
+	segmentAt := func(i int) []byte { return original[header+i*segment : header+(i+1)*segment] }
+
+	cases := map[string][]byte{
+		// segmen terakhir hilang: dua segmen penuh yang tersisa tidak
+		// bertanda final sehingga tetap terdeteksi
+		"terpotong di batas segmen": original[:header+2*segment],
+		"terpotong di tengah":       original[:len(original)-10],
+		"segmen ditukar":            bytes.Join([][]byte{original[:header], segmentAt(1), segmentAt(0), original[header+2*segment:]}, nil),
+		"byte diubah":               append(append([]byte{}, original[:header+5]...), append([]byte{original[header+5] ^ 1}, original[header+6:]...)...),
+	}
+
+	for name, tampered := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(path, tampered, 0o640); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := readAll(s.Open(object.PublicID, "raw")); !errors.Is(err, errCorruptedCiphertext) {
+				t.Fatalf("Open: err = %v, want errCorruptedCiphertext", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedRotateKeys(t *testing.T) {
+	db, table := newFakeDB(t)
+	local := newLocal(t)
+	k1, k2 := masterKey(), masterKey()
+
+	old := newEncrypted(t, local, db, "k1:"+k1, "")
+
+	data := make([]byte, encryptedSegmentSize+1)
+	rand.Read(data)
+
+	object, err := old.Put(bytes.NewReader(data), PutOptions{FileName: "scan.pdf", Folder: "dokumen", ResourceType: "raw", OwnerID: "pegawai"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	rotating := newEncrypted(t, local, db, "k1:"+k1+",k2:"+k2, "k2")
+
+	rotated, err := rotating.RotateKeys()
+	if err != nil || rotated != 1 {
+		t.Fatalf("RotateKeys = %d, %v, want 1", rotated, err)
+	}
+	if keyID := table.rows[object.PublicID]["key_id"]; keyID != "k2" {
+		t.Fatalf("key_id = %v, want k2", keyID)
+	}
+
+	if rotated, err := rotating.RotateKeys(); err != nil || rotated != 0 {
+		t.Fatalf("RotateKeys kedua = %d, %v, want 0", rotated, err)
+	}
+
+	// Setelah rotasi master key lama boleh dibuang.
+	current := newEncrypted(t, local, db, "k2:"+k2, "")
+
+	got, err := readAll(current.Open(object.PublicID, "raw"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Open setelah rotasi: %d byte, err %v", len(got), err)
+	}
+
+	// Master key lama saja tidak lagi bisa membuka data key.
+	if _, err := readAll(old.Open(object.PublicID, "raw")); err == nil {
+		t.Fatal("master key lama masih bisa membuka file setelah rotasi")
+	}
+
+	if err := current.Delete(object.PublicID, "raw"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := table.rows[object.PublicID]; ok {
+		t.Fatal("data key tidak ikut dihapus")
+	}
+}
+
+func TestEncryptedReadsLegacyFiles(t *testing.T) {
+	db, _ := newFakeDB(t)
+	local := newLocal(t)
+	s := newEncrypted(t, local, db, "k1:"+masterKey(), "")
+
+	// File yang disimpan sebelum enkripsi aktif tidak punya data key.
+	object, err := local.Put(strings.NewReader("file lama"), PutOptions{FileName: "lama.pdf", Folder: "dokumen", ResourceType: "raw", OwnerID: "pegawai"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := readAll(s.Open(object.PublicID, "raw"))
+	if err != nil || string(got) != "file lama" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	if n, err := s.Size(object.PublicID, "raw"); err != nil || n != int64(len("file lama")) {
+		t.Fatalf("Size = %d, %v", n, err)
+	}
+}
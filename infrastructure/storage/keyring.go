@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Keyring berisi master key untuk membungkus data key. Key lama tetap
+// disimpan agar data key yang belum dirotasi masih bisa dibuka.
+type Keyring struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+// LoadKeyringFromEnv membaca env STORAGE_MASTER_KEYS dengan format
+// "id1:base64,id2:base64" (masing-masing 32 byte) dan STORAGE_MASTER_KEY_ID
+// sebagai key aktif. Mengembalikan nil jika enkripsi tidak diaktifkan.
+func LoadKeyringFromEnv() (*Keyring, error) {
+	raw := strings.TrimSpace(os.Getenv("STORAGE_MASTER_KEYS"))
+	if raw == "" {
+		return nil, nil
+	}
+
+	keyring := &Keyring{
+		activeID: strings.TrimSpace(os.Getenv("STORAGE_MASTER_KEY_ID")),
+		keys:     map[string]cipher.AEAD{},
+	}
+
+	var lastID string
+	for _, entry := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("format STORAGE_MASTER_KEYS tidak valid")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("master key %s harus 32 byte dalam base64", id)
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		keyring.keys[id] = aead
+		lastID = id
+	}
+
+	if keyring.activeID == "" {
+		if len(keyring.keys) > 1 {
+			return nil, fmt.Errorf("STORAGE_MASTER_KEY_ID wajib diisi jika ada lebih dari satu master key")
+		}
+		keyring.activeID = lastID
+	}
+
+	if _, ok := keyring.keys[keyring.activeID]; !ok {
+		return nil, fmt.Errorf("master key %s tidak ditemukan di STORAGE_MASTER_KEYS", keyring.activeID)
+	}
+
+	return keyring, nil
+}
+
+// wrap mengenkripsi data key dengan master key aktif.
+func (k *Keyring) wrap(dataKey []byte) (string, []byte, error) {
+	aead := k.keys[k.activeID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return k.activeID, aead.Seal(nonce, nonce, dataKey, []byte(k.activeID)), nil
+}
+
+// unwrap membuka data key dengan master key yang dulu dipakai membungkusnya.
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %s tidak tersedia", keyID)
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("data key rusak")
+	}
+
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("data key tidak dapat dibuka: %v", err)
+	}
+
+	return dataKey, nil
+}
+
+// DataKey menyimpan data key file yang sudah dibungkus master key. File di
+// storage tidak pernah menyimpan key-nya sendiri, sehingga rotasi master key
+// cukup memperbarui baris ini.
+type DataKey struct {
+	PublicID    string `gorm:"type:varchar(255);primaryKey"`
+	KeyID       string `gorm:"type:varchar(50);index"`
+	WrappedKey  []byte `gorm:"type:varbinary(128)"`
+	NoncePrefix []byte `gorm:"type:varbinary(16)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// KeyRotator diimplementasikan storage terenkripsi yang dapat membungkus
+// ulang semua data key dengan master key aktif.
+type KeyRotator interface {
+	RotateKeys() (int, error)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func findDataKey(db *gorm.DB, publicID string) (*DataKey, error) {
+	var dataKey DataKey
+	err := db.First(&dataKey, "public_id = ?", publicID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataKey, nil
+}
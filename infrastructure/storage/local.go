@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocalStorage menyimpan file di disk server, dipisah per pegawai:
+// <root>/<owner_id>/<folder>/<nama_unik>.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if root == "" {
+		root = "storage_data"
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("root storage tidak valid: %v", err)
+	}
+
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, fmt.Errorf("gagal membuat root storage: %v", err)
+	}
+
+	return &LocalStorage{root: abs}, nil
+}
+
+func (s *LocalStorage) Put(file io.Reader, opts PutOptions) (Object, error) {
+	owner := sanitizePathSegment(opts.OwnerID)
+	if owner == "" {
+		owner = "shared"
+	}
+
+	publicID := filepath.ToSlash(filepath.Join(owner, sanitizePathSegment(opts.Folder), uniqueFileName(opts.FileName)))
+
+	path, err := s.path(publicID)
+	if err != nil {
+		return Object{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return Object{}, fmt.Errorf("gagal membuat folder: %v", err)
+	}
+
+	tmp := path + ".part"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return Object{}, fmt.Errorf("gagal membuat file: %v", err)
+	}
+
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return Object{}, fmt.Errorf("gagal menulis file: %v", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return Object{}, fmt.Errorf("gagal menulis file: %v", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Object{}, fmt.Errorf("gagal menyimpan file: %v", err)
+	}
+
+	return Object{
+		PublicID:     publicID,
+		ResourceType: opts.ResourceType,
+	}, nil
+}
+
+func (s *LocalStorage) Delete(publicID, resourceType string) error {
+	path, err := s.path(publicID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("gagal menghapus file: %v", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Exists(publicID, resourceType string) (bool, error) {
+	path, err := s.path(publicID)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// List menelusuri <root>/<owner_id>/<folder> untuk semua pegawai.
+func (s *LocalStorage) List(folder, resourceType string) ([]ListedObject, error) {
+	matches, err := filepath.Glob(filepath.Join(s.root, "*", sanitizePathSegment(folder), "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []ListedObject{}
+	for _, match := range matches {
+		if strings.HasSuffix(match, ".part") {
+			continue
+		}
+
+		info, err := os.Stat(match)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rel, err := filepath.Rel(s.root, match)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, ListedObject{PublicID: filepath.ToSlash(rel), CreatedAt: info.ModTime()})
+	}
+
+	return objects, nil
+}
+
+// URL selalu kosong: file lokal hanya boleh diambil lewat endpoint download
+// yang terautentikasi.
+func (s *LocalStorage) URL(publicID, resourceType string) (string, error) {
+	return "", nil
+}
+
+func (s *LocalStorage) Open(publicID, resourceType string) (io.ReadCloser, error) {
+	path, err := s.path(publicID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("file tidak dapat dibuka: %v", err)
+	}
+
+	return f, nil
+}
+
+func (s *LocalStorage) Size(publicID, resourceType string) (int64, error) {
+	path, err := s.path(publicID)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("file tidak dapat dibaca: %v", err)
+	}
+
+	return info.Size(), nil
+}
+
+func (s *LocalStorage) OpenRange(publicID, resourceType string, offset int64) (io.ReadCloser, error) {
+	f, err := s.Open(publicID, resourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.(*os.File).Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// path mengubah public id menjadi path absolut dan menolak path yang keluar
+// dari root storage.
+func (s *LocalStorage) path(publicID string) (string, error) {
+	if publicID == "" {
+		return "", fmt.Errorf("public id kosong")
+	}
+
+	path := filepath.Join(s.root, filepath.FromSlash(publicID))
+	if !strings.HasPrefix(path, s.root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("public id tidak valid: %s", publicID)
+	}
+
+	return path, nil
+}
+
+func sanitizePathSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+
+	return strings.Trim(s, ".")
+}
+
+func uniqueFileName(originalName string) string {
+	base := filepath.Base(originalName)
+	ext := sanitizePathSegment(strings.ToLower(filepath.Ext(base)))
+	if ext != "" {
+		ext = "." + ext
+	}
+
+	name := sanitizePathSegment(strings.TrimSuffix(base, filepath.Ext(base)))
+	if name == "" {
+		name = "file"
+	}
+
+	return fmt.Sprintf("%s_%d_%s%s", name, time.Now().UnixNano(), uuid.New().String()[:8], ext)
+}
@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3PartSize        = 5 << 20
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Storage menyimpan file di object storage yang kompatibel dengan API S3
+// (AWS S3, MinIO, SeaweedFS). Public id dokumen dipakai sebagai object key dan
+// resource type disimpan sebagai metadata object.
+type S3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+
+	// streamClient dipakai Open dan OpenRange, tanpa batas waktu total.
+	streamClient *http.Client
+}
+
+func NewS3StorageFromEnv() (*S3Storage, error) {
+	return NewS3Storage(
+		os.Getenv("S3_ENDPOINT"),
+		os.Getenv("S3_REGION"),
+		os.Getenv("S3_BUCKET"),
+		os.Getenv("S3_ACCESS_KEY"),
+		os.Getenv("S3_SECRET_KEY"),
+		os.Getenv("S3_USE_PATH_STYLE") != "false",
+	)
+}
+
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) (*S3Storage, error) {
+	if endpoint == "" || bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("konfigurasi S3 tidak lengkap. Pastikan S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY dan S3_SECRET_KEY sudah terisi")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT tidak valid: %s", endpoint)
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		pathStyle: pathStyle,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+
+		streamClient: newStreamingClient(),
+	}, nil
+}
+
+func (s *S3Storage) Put(file io.Reader, opts PutOptions) (Object, error) {
+	key := strings.Trim(opts.Folder, "/") + "/"
+	if owner := sanitizePathSegment(opts.OwnerID); owner != "" {
+		key += owner + "/"
+	}
+	key += uniqueFileName(opts.FileName)
+
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(opts.FileName)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	headers := map[string]string{
+		"Content-Type":             contentType,
+		"x-amz-meta-resource-type": opts.ResourceType,
+	}
+
+	// Bagian pertama dibaca dulu: file kecil cukup dikirim dengan satu PUT,
+	// file besar dikirim per bagian agar memori tetap terbatas.
+	buf := make([]byte, s3PartSize)
+	n, err := io.ReadFull(file, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if err := s.putObject(key, buf[:n], headers); err != nil {
+			return Object{}, err
+		}
+	} else if err != nil {
+		return Object{}, fmt.Errorf("gagal membaca file: %v", err)
+	} else if err := s.multipartUpload(key, buf, file, headers); err != nil {
+		return Object{}, err
+	}
+
+	return Object{
+		PublicID:     key,
+		ResourceType: opts.ResourceType,
+	}, nil
+}
+
+func (s *S3Storage) Delete(publicID, resourceType string) error {
+	resp, err := s.do(http.MethodDelete, publicID, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", resp)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Exists(publicID, resourceType string) (bool, error) {
+	resp, err := s.do(http.MethodHead, publicID, nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, s3Error("head", resp)
+	}
+}
+
+// URL mengembalikan presigned URL dengan masa berlaku default.
+func (s *S3Storage) URL(publicID, resourceType string) (string, error) {
+	return s.SignedURL(publicID, resourceType, URLTTL())
+}
+
+// SignedURL membuat presigned GET URL (AWS Signature V4) yang berlaku selama ttl.
+func (s *S3Storage) SignedURL(publicID, resourceType string, ttl time.Duration) (string, error) {
+	if publicID == "" {
+		return "", fmt.Errorf("public id kosong")
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	u := s.objectURL(publicID)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(now, amzDate, scope, canonicalRequest))
+	u.RawQuery = canonicalQuery(query)
+
+	return u.String(), nil
+}
+
+func (s *S3Storage) Open(publicID, resourceType string) (io.ReadCloser, error) {
+	return s.OpenRange(publicID, resourceType, 0)
+}
+
+func (s *S3Storage) Size(publicID, resourceType string) (int64, error) {
+	resp, err := s.do(http.MethodHead, publicID, nil, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, s3Error("head", resp)
+	}
+
+	return resp.ContentLength, nil
+}
+
+// OpenRange memakai presigned URL agar header Range tidak perlu ikut
+// ditandatangani.
+func (s *S3Storage) OpenRange(publicID, resourceType string, offset int64) (io.ReadCloser, error) {
+	signed, err := s.SignedURL(publicID, resourceType, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, signed, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return openHTTPRange(s.streamClient, req, offset)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List memakai ListObjectsV2 dengan prefix folder.
+func (s *S3Storage) List(folder, resourceType string) ([]ListedObject, error) {
+	objects := []ListedObject{}
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {strings.Trim(folder, "/") + "/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, s3Error("list", resp)
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("respon list tidak valid: %v", err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, ListedObject{PublicID: content.Key, CreatedAt: content.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) putObject(key string, data []byte, headers map[string]string) error {
+	resp, err := s.do(http.MethodPut, key, nil, headers, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+
+	return nil
+}
+
+type s3InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteUpload struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+func (s *S3Storage) multipartUpload(key string, first []byte, rest io.Reader, headers map[string]string) error {
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("create multipart upload", resp)
+	}
+
+	var initiated s3InitiateResult
+	if err := xml.NewDecoder(resp.Body).Decode(&initiated); err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("respon multipart upload tidak valid: %v", err)
+	}
+
+	uploadQuery := func(partNumber int) url.Values {
+		q := url.Values{"uploadId": {initiated.UploadID}}
+		if partNumber > 0 {
+			q.Set("partNumber", strconv.Itoa(partNumber))
+		}
+		return q
+	}
+
+	abort := func(cause error) error {
+		if resp, err := s.do(http.MethodDelete, key, uploadQuery(0), nil, nil); err == nil {
+			resp.Body.Close()
+		}
+		return cause
+	}
+
+	var complete s3CompleteUpload
+	part := first
+	for partNumber := 1; ; partNumber++ {
+		resp, err := s.do(http.MethodPut, key, uploadQuery(partNumber), nil, part)
+		if err != nil {
+			return abort(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return abort(s3Error("upload part", resp))
+		}
+
+		complete.Parts = append(complete.Parts, s3CompletePart{
+			PartNumber: partNumber,
+			ETag:       resp.Header.Get("ETag"),
+		})
+
+		buf := make([]byte, s3PartSize)
+		n, err := io.ReadFull(rest, buf)
+		if n == 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(fmt.Errorf("gagal membaca file: %v", err))
+		}
+		part = buf[:n]
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return abort(err)
+	}
+
+	resp, err = s.do(http.MethodPost, key, uploadQuery(0), map[string]string{"Content-Type": "application/xml"}, body)
+	if err != nil {
+		return abort(err)
+	}
+	defer resp.Body.Close()
+
+	// S3 dapat mengembalikan 200 dengan body <Error> untuk complete multipart.
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || bytes.Contains(respBody, []byte("<Error>")) {
+		return abort(fmt.Errorf("s3 complete multipart upload gagal (status %d): %s", resp.StatusCode, string(respBody)))
+	}
+
+	return nil
+}
+
+// do mengirim request yang ditandatangani dengan AWS Signature V4.
+func (s *S3Storage) do(method, key string, query url.Values, headers map[string]string, body []byte) (*http.Response, error) {
+	u := s.objectURL(key)
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3UnsignedPayload)
+
+	signedNames := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			signedNames = append(signedNames, lower)
+		}
+	}
+	sort.Strings(signedNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedNames {
+		value := u.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(signedNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, s.signature(now, amzDate, scope, canonicalRequest)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to S3 failed: %v", err)
+	}
+
+	return resp, nil
+}
+
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	escapedKey := s3EscapePath(key)
+
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+		u.RawPath = "/" + s.bucket + "/" + escapedKey
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+		u.RawPath = "/" + escapedKey
+	}
+
+	return &u
+}
+
+func (s *S3Storage) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape melakukan URI encode sesuai aturan SigV4 (RFC 3986).
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Error(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("s3 %s gagal (status %d): %s", action, resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeS3Bucket    = "dokumen"
+	fakeS3Region    = "ap-southeast-3"
+	fakeS3AccessKey = "AKIDTEST"
+	fakeS3SecretKey = "rahasia/TEST+key"
+)
+
+// fakeS3 adalah server S3 minimal di dalam proses. Setiap request diperiksa
+// tanda tangan SigV4-nya dengan implementasi terpisah dari kode yang diuji,
+// baik lewat header Authorization maupun presigned query.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]http.Header
+	created map[string]time.Time
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	t.Helper()
+
+	fake := &fakeS3{
+		objects: map[string][]byte{},
+		meta:    map[string]http.Header{},
+		created: map[string]time.Time{},
+		uploads: map[string]map[int][]byte{},
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s3, err := NewS3Storage(server.URL, fakeS3Region, fakeS3Bucket, fakeS3AccessKey, fakeS3SecretKey, true)
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	return fake, s3
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := verifySigV4(r); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>%s</Message></Error>", err)
+		return
+	}
+
+	prefix := "/" + fakeS3Bucket + "/"
+	if strings.TrimSuffix(r.URL.Path, "/") == "/"+fakeS3Bucket && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		f.meta[key] = r.Header.Clone()
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		body, _ := io.ReadAll(r.Body)
+		parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var complete s3CompleteUpload
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf("\"etag-%d\"", i+1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data = append(data, parts[part.PartNumber]...)
+		}
+		f.objects[key] = data
+		f.created[key] = time.Now().UTC()
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		f.meta[key] = r.Header.Clone()
+		f.created[key] = time.Now().UTC()
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prefix := r.URL.Query().Get("prefix")
+	var b strings.Builder
+	b.WriteString("<ListBucketResult>")
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			fmt.Fprintf(&b, "<Contents><Key>%s</Key><LastModified>%s</LastModified></Contents>", key, f.created[key].Format("2006-01-02T15:04:05.000Z"))
+		}
+	}
+	b.WriteString("<IsTruncated>false</IsTruncated></ListBucketResult>")
+	fmt.Fprint(w, b.String())
+}
+
+// verifySigV4 menghitung ulang tanda tangan AWS Signature V4 dari request
+// yang diterima server.
+func verifySigV4(r *http.Request) error {
+	query := r.URL.Query()
+	presigned := query.Get("X-Amz-Signature") != ""
+
+	var credential, signedHeaders, signature, amzDate, payloadHash string
+	if presigned {
+		if query.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" {
+			return fmt.Errorf("algoritma tidak didukung")
+		}
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		signature = query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		payloadHash = "UNSIGNED-PAYLOAD"
+
+		signedAt, err := time.Parse("20060102T150405Z", amzDate)
+		if err != nil {
+			return fmt.Errorf("X-Amz-Date tidak valid")
+		}
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || time.Now().After(signedAt.Add(time.Duration(expires)*time.Second)) {
+			return fmt.Errorf("presigned URL kedaluwarsa")
+		}
+		query.Del("X-Amz-Signature")
+	} else {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+			return fmt.Errorf("header Authorization tidak ada")
+		}
+		for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+		amzDate = r.Header.Get("x-amz-date")
+		payloadHash = r.Header.Get("x-amz-content-sha256")
+	}
+
+	scope := strings.SplitN(credential, "/", 2)
+	if len(scope) != 2 || scope[0] != fakeS3AccessKey {
+		return fmt.Errorf("credential tidak dikenal: %s", credential)
+	}
+	scopeParts := strings.Split(scope[1], "/")
+	if len(scopeParts) != 4 || scopeParts[1] != fakeS3Region || scopeParts[2] != "s3" || scopeParts[3] != "aws4_request" {
+		return fmt.Errorf("scope tidak valid: %s", scope[1])
+	}
+
+	names := strings.Split(signedHeaders, ";")
+	if !sort.StringsAreSorted(names) {
+		return fmt.Errorf("SignedHeaders tidak urut")
+	}
+	var headers strings.Builder
+	for _, name := range names {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		awsEncode(r.URL.Path, false),
+		awsCanonicalQuery(query),
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope[1] + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + fakeS3SecretKey)
+	for _, part := range scopeParts {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	if expected := hex.EncodeToString(mac.Sum(nil)); expected != signature {
+		return fmt.Errorf("signature tidak cocok")
+	}
+
+	return nil
+}
+
+// awsEncode melakukan URI encode RFC 3986. Slash dipertahankan untuk path.
+func awsEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, ch := range []byte(s) {
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEncode(name, true)+"="+awsEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+
+	content := []byte("%PDF-1.4 surat keputusan")
+	obj, err := s3.Put(bytes.NewReader(content), PutOptions{
+		FileName:     "SK Kepala Dinas.pdf",
+		Folder:       "arsip dinsos",
+		ResourceType: "raw",
+		OwnerID:      "pegawai-1",
+	})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if !strings.HasPrefix(obj.PublicID, "arsip dinsos/pegawai-1/SK_Kepala_Dinas_") || !strings.HasSuffix(obj.PublicID, ".pdf") {
+		t.Fatalf("public id tidak sesuai: %s", obj.PublicID)
+	}
+	if got := fake.meta[obj.PublicID].Get("x-amz-meta-resource-type"); got != "raw" {
+		t.Fatalf("metadata resource type = %q, want raw", got)
+	}
+	if got := fake.meta[obj.PublicID].Get("Content-Type"); got != "application/pdf" {
+		t.Fatalf("Content-Type = %q, want application/pdf", got)
+	}
+
+	exists, err := s3.Exists(obj.PublicID, "raw")
+	if err != nil || !exists {
+		t.Fatalf("Exists = %v, %v; want true", exists, err)
+	}
+
+	size, err := s3.Size(obj.PublicID, "raw")
+	if err != nil || size != int64(len(content)) {
+		t.Fatalf("Size = %d, %v; want %d", size, err, len(content))
+	}
+
+	reader, err := s3.Open(obj.PublicID, "raw")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(got, content) {
+		t.Fatalf("Open = %q, want %q", got, content)
+	}
+
+	reader, err = s3.OpenRange(obj.PublicID, "raw", 9)
+	if err != nil {
+		t.Fatalf("OpenRange: %v", err)
+	}
+	got, _ = io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(got, content[9:]) {
+		t.Fatalf("OpenRange = %q, want %q", got, content[9:])
+	}
+
+	objects, err := s3.List("arsip dinsos", "raw")
+	if err != nil || len(objects) != 1 || objects[0].PublicID != obj.PublicID {
+		t.Fatalf("List = %v, %v; want [%s]", objects, err, obj.PublicID)
+	}
+	if age := time.Since(objects[0].CreatedAt); age < 0 || age > time.Minute {
+		t.Fatalf("CreatedAt hasil List tidak sesuai: %v", objects[0].CreatedAt)
+	}
+
+	if err := s3.Delete(obj.PublicID, "raw"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	exists, err = s3.Exists(obj.PublicID, "raw")
+	if err != nil || exists {
+		t.Fatalf("Exists setelah Delete = %v, %v; want false", exists, err)
+	}
+
+	if _, err := s3.Open(obj.PublicID, "raw"); err == nil {
+		t.Fatal("Open setelah Delete seharusnya gagal")
+	}
+
+	// Menghapus object yang sudah tidak ada tetap berhasil.
+	if err := s3.Delete(obj.PublicID, "raw"); err != nil {
+		t.Fatalf("Delete ulang: %v", err)
+	}
+}
+
+func TestS3PutMultipart(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), (s3PartSize*2+1000)/16)
+	obj, err := s3.Put(bytes.NewReader(content), PutOptions{FileName: "besar.bin", Folder: "dokumen", ResourceType: "raw"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if !bytes.Equal(fake.objects[obj.PublicID], content) {
+		t.Fatalf("isi object multipart tidak sama (%d byte, want %d)", len(fake.objects[obj.PublicID]), len(content))
+	}
+	if len(fake.uploads) != 0 {
+		t.Fatalf("multipart upload belum diselesaikan: %v", fake.uploads)
+	}
+}
+
+func TestS3PresignedGet(t *testing.T) {
+	_, s3 := newFakeS3(t)
+
+	content := []byte("foto ktp")
+	obj, err := s3.Put(bytes.NewReader(content), PutOptions{FileName: "ktp.jpg", Folder: "arsip dinsos", ResourceType: "image"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	signed, err := s3.SignedURL(obj.PublicID, "image", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, content) {
+		t.Fatalf("GET presigned URL = %d %q, want 200 %q", resp.StatusCode, got, content)
+	}
+
+	// URL yang diubah tidak boleh diterima.
+	tampered := strings.Replace(signed, "ktp", "kk", 1)
+	resp, err = http.Get(tampered)
+	if err != nil {
+		t.Fatalf("GET tampered URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("GET tampered URL = %d, want 403", resp.StatusCode)
+	}
+
+	// URL yang sudah kedaluwarsa ditolak.
+	expired, err := s3.SignedURL(obj.PublicID, "image", -time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	resp, err = http.Get(expired)
+	if err != nil {
+		t.Fatalf("GET expired URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("GET expired URL = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestS3RejectsWrongSecret(t *testing.T) {
+	_, s3 := newFakeS3(t)
+	s3.secretKey = "salah"
+
+	if _, err := s3.Put(strings.NewReader("x"), PutOptions{FileName: "a.txt", Folder: "dokumen", ResourceType: "raw"}); err == nil {
+		t.Fatal("Put dengan secret key salah seharusnya gagal")
+	}
+}
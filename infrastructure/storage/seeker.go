@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RangeReadSeeker membungkus RangeOpener menjadi io.ReadSeeker sehingga file
+// remote bisa dilayani dengan http.ServeContent. Koneksi ke storage baru
+// dibuka saat Read dan dibuka ulang dari posisi baru setelah Seek.
+type RangeReadSeeker struct {
+	opener       RangeOpener
+	publicID     string
+	resourceType string
+	size         int64
+	offset       int64
+	body         io.ReadCloser
+}
+
+func NewRangeReadSeeker(opener RangeOpener, publicID, resourceType string) (*RangeReadSeeker, error) {
+	size, err := opener.Size(publicID, resourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeReadSeeker{
+		opener:       opener,
+		publicID:     publicID,
+		resourceType: resourceType,
+		size:         size,
+	}, nil
+}
+
+func (r *RangeReadSeeker) Size() int64 {
+	return r.size
+}
+
+func (r *RangeReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.opener.OpenRange(r.publicID, r.resourceType, r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *RangeReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.offset + offset
+	case io.SeekEnd:
+		next = r.size + offset
+	default:
+		return 0, errors.New("whence tidak valid")
+	}
+
+	if next < 0 {
+		return 0, errors.New("posisi negatif")
+	}
+
+	if next != r.offset {
+		r.closeBody()
+		r.offset = next
+	}
+
+	return next, nil
+}
+
+func (r *RangeReadSeeker) Close() error {
+	return r.closeBody()
+}
+
+func (r *RangeReadSeeker) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// newStreamingClient membuat HTTP client untuk mengalirkan isi file ke
+// client. Tidak ada batas waktu total karena lamanya transfer mengikuti
+// kecepatan client; yang dibatasi hanya waktu koneksi dan waktu menunggu
+// header respons dari storage.
+func newStreamingClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &http.Client{Transport: transport}
+}
+
+// errHTTPNotFound dikembalikan openHTTPRange untuk status 404.
+var errHTTPNotFound = errors.New("file tidak ditemukan")
+
+// openHTTPRange mengirim request GET dengan header Range mulai dari offset.
+// Server yang mengabaikan Range (status 200) tetap didukung dengan membuang
+// byte sebelum offset.
+func openHTTPRange(client *http.Client, req *http.Request, offset int64) (io.ReadCloser, error) {
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errHTTPNotFound
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("file tidak dapat diambil (status %d)", resp.StatusCode)
+	}
+}
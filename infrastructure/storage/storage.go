@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// PutOptions menjelaskan file yang akan disimpan ke storage.
+type PutOptions struct {
+	FileName     string
+	Folder       string
+	ResourceType string
+	OwnerID      string
+}
+
+// Object adalah hasil penyimpanan file di storage.
+type Object struct {
+	PublicID     string
+	URL          string
+	ResourceType string
+}
+
+// Storage adalah backend penyimpanan file dokumen.
+type Storage interface {
+	Put(file io.Reader, opts PutOptions) (Object, error)
+	Delete(publicID, resourceType string) error
+	Exists(publicID, resourceType string) (bool, error)
+	URL(publicID, resourceType string) (string, error)
+	Open(publicID, resourceType string) (io.ReadCloser, error)
+}
+
+// Signer diimplementasikan backend yang dapat membuat URL berumur pendek.
+type Signer interface {
+	SignedURL(publicID, resourceType string, ttl time.Duration) (string, error)
+}
+
+// ListedObject adalah satu file hasil listing folder. CreatedAt dipakai job
+// rekonsiliasi untuk melewati file yang baru saja diupload.
+type ListedObject struct {
+	PublicID  string
+	CreatedAt time.Time
+}
+
+// Lister diimplementasikan backend yang dapat menampilkan semua file di
+// sebuah folder, dipakai oleh job rekonsiliasi.
+type Lister interface {
+	List(folder, resourceType string) ([]ListedObject, error)
+}
+
+// RangeOpener diimplementasikan backend yang dapat membaca file mulai dari
+// offset tertentu, dipakai untuk melayani HTTP Range request.
+type RangeOpener interface {
+	Size(publicID, resourceType string) (int64, error)
+	OpenRange(publicID, resourceType string, offset int64) (io.ReadCloser, error)
+}
+
+// Privatizer diimplementasikan backend yang dapat memindahkan file publik
+// lama ke mode akses privat. Object yang dikembalikan tidak memiliki URL
+// publik.
+type Privatizer interface {
+	MakePrivate(publicID, resourceType string) (Object, error)
+}
+
+// URLTTL adalah masa berlaku URL bertanda tangan, diatur lewat env
+// STORAGE_URL_TTL (contoh: 15m). Default 15 menit.
+func URLTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("STORAGE_URL_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+
+	return 15 * time.Minute
+}
+
+// New membuat storage sesuai env STORAGE_DRIVER (default: cloudinary).
+func New() (Storage, error) {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_DRIVER")))
+
+	switch driver {
+	case "", "cloudinary":
+		return NewCloudinaryStorage(), nil
+	case "local":
+		return NewLocalStorage(os.Getenv("STORAGE_LOCAL_ROOT"))
+	case "s3":
+		return NewS3StorageFromEnv()
+	default:
+		return nil, fmt.Errorf("STORAGE_DRIVER tidak dikenal: %s", driver)
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	documentStaff "BackendKantorDinsos/domain/document_staff"
+	"BackendKantorDinsos/domain/document_type"
 	"BackendKantorDinsos/domain/employee"
 	"BackendKantorDinsos/domain/login"
+	"BackendKantorDinsos/domain/storage_quota"
 	"log"
 	"os"
+	"time"
 
+	"BackendKantorDinsos/infrastructure/clamav"
 	"BackendKantorDinsos/infrastructure/database"
 	"BackendKantorDinsos/infrastructure/routes"
+	"BackendKantorDinsos/infrastructure/storage"
 
 	"BackendKantorDinsos/infrastructure/middleware"
 
@@ -15,14 +21,65 @@ import (
 )
 
 func main() {
-	r := gin.Default()
-
 	database.ConnectDatabase()
 
-	database.DB.AutoMigrate(
+	if err := database.Migrate(
 		&employee.Employee{},
 		&login.RefreshToken{},
-	)
+		&documentStaff.DocumentStaff{},
+		&documentStaff.TusUpload{},
+		&documentStaff.DocumentVersion{},
+		&documentStaff.StorageDeletion{},
+		&documentStaff.DocumentAccessLog{},
+		&documentStaff.IntegrityCheck{},
+		&storage.DataKey{},
+		&documentStaff.SecurityEvent{},
+		&documentStaff.DocumentText{},
+		&documentStaff.DocumentSearchEntry{},
+		&storage_quota.StorageQuota{},
+		&document_type.DocumentType{},
+	); err != nil {
+		log.Fatal("❌ Gagal migrasi database:", err)
+	}
+
+	store, err := storage.New()
+	if err != nil {
+		log.Fatal("❌ Gagal inisialisasi storage:", err)
+	}
+
+	store, err = storage.WithEncryption(store, database.DB)
+	if err != nil {
+		log.Fatal("❌ Gagal inisialisasi enkripsi storage:", err)
+	}
+	documentStaff.SetStorage(store)
+
+	scanner, scanMode, err := clamav.NewClientFromEnv()
+	if err != nil {
+		log.Fatal("❌ Konfigurasi antivirus tidak valid:", err)
+	}
+	if scanner != nil {
+		if err := scanner.Ping(); err != nil {
+			log.Printf("⚠️ clamd belum dapat dihubungi (mode %s): %v", scanMode, err)
+		}
+	}
+	documentStaff.SetVirusScanner(scanner, scanMode)
+
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	documentStaff.StartTusCleanup(time.Hour)
+	documentStaff.StartTrashPurger(time.Hour)
+	documentStaff.StartStorageDeletionWorker(time.Minute)
+	documentStaff.StartThumbnailWorker()
+	documentStaff.StartTextWorker()
+	documentStaff.StartOCRWorker()
+
+	if interval, err := time.ParseDuration(os.Getenv("RECONCILE_INTERVAL")); err == nil && interval > 0 {
+		documentStaff.StartReconcileScheduler(interval, os.Getenv("RECONCILE_REPAIR") == "true")
+	}
+
+	r := gin.Default()
 
 	r.Use(middleware.CORSMiddleware())
 	r.Use(middleware.XSSBlocker())
@@ -30,6 +87,8 @@ func main() {
 	routes.EmployeeRoutes(r)
 	routes.AuthRoutes(r)
 	routes.DocumentStaffRoutes(r)
+	routes.StorageQuotaRoutes(r)
+	routes.DocumentTypeRoutes(r)
 
 	port := os.Getenv("PORT")
 	if port == "" {